@@ -0,0 +1,160 @@
+package routing_table
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// OriginAggregation is one line of a CIDR report: the prefixes announced by an
+// origin ASN and the smallest set of aggregates that covers exactly the same
+// address space.
+type OriginAggregation struct {
+	Origin     uint32
+	Prefixes   int
+	Aggregates []netip.Prefix
+}
+
+// Saved returns how many prefixes could be withdrawn if the origin announced
+// only its aggregates.
+func (o OriginAggregation) Saved() int {
+	return o.Prefixes - len(o.Aggregates)
+}
+
+// Factor returns the deaggregation factor: announced prefixes per aggregate.
+func (o OriginAggregation) Factor() float64 {
+	if len(o.Aggregates) == 0 {
+		return 0
+	}
+	return float64(o.Prefixes) / float64(len(o.Aggregates))
+}
+
+// CIDRReport computes, for every origin ASN, the minimal aggregates covering
+// its announced prefixes. Only the best path of each prefix is considered,
+// and prefixes are only aggregated together when their best paths share the
+// same attributes, so an aggregate never hides a traffic engineering
+// difference. Origins are ranked by Saved, most deaggregated first.
+func (r *Rib) CIDRReport() []OriginAggregation {
+	// Interned attributes compare by pointer, so grouping on *RouteAttributes
+	// groups on identical AS path, communities and LocalPref.
+	groups := make(map[*RouteAttributes][]netip.Prefix)
+	collect := func(prefix netip.Prefix, n *node) {
+		attr := n.bestPath()
+		if attr == nil || len(attr.AsPath) == 0 {
+			return
+		}
+		groups[attr] = append(groups[attr], prefix)
+	}
+
+	r.v4mu.RLock()
+	r.walkIPv4(collect)
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(collect)
+	r.v6mu.RUnlock()
+
+	byOrigin := make(map[uint32]*OriginAggregation)
+	for attr, prefixes := range groups {
+		origin := attr.AsPath[len(attr.AsPath)-1]
+		o := byOrigin[origin]
+		if o == nil {
+			o = &OriginAggregation{Origin: origin}
+			byOrigin[origin] = o
+		}
+		o.Prefixes += len(prefixes)
+		o.Aggregates = append(o.Aggregates, AggregatePrefixes(prefixes)...)
+	}
+
+	report := make([]OriginAggregation, 0, len(byOrigin))
+	for _, o := range byOrigin {
+		sortPrefixes(o.Aggregates)
+		report = append(report, *o)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if si, sj := report[i].Saved(), report[j].Saved(); si != sj {
+			return si > sj
+		}
+		if report[i].Prefixes != report[j].Prefixes {
+			return report[i].Prefixes > report[j].Prefixes
+		}
+		return report[i].Origin < report[j].Origin
+	})
+	return report
+}
+
+// AggregatePrefixes returns the smallest set of prefixes covering exactly the
+// same address space as the input. Prefixes covered by another are dropped and
+// sibling pairs are repeatedly merged into their parent. The input may mix
+// address families and is not modified.
+func AggregatePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	if len(prefixes) == 0 {
+		return nil
+	}
+	sorted := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p.IsValid() {
+			sorted = append(sorted, p.Masked())
+		}
+	}
+	sortPrefixes(sorted)
+
+	// Drop duplicates and prefixes covered by an earlier, shorter one. Sorting
+	// by address then length puts every covering prefix before what it covers.
+	set := make(map[netip.Prefix]bool, len(sorted))
+	var last netip.Prefix
+	for _, p := range sorted {
+		if last.IsValid() && last.Addr().BitLen() == p.Addr().BitLen() && last.Overlaps(p) {
+			continue
+		}
+		set[p] = true
+		last = p
+	}
+
+	// Merge siblings from the longest length up, so a merged parent can
+	// itself be merged on the next pass.
+	for bits := 128; bits > 0; bits-- {
+		for p := range set {
+			if p.Bits() != bits {
+				continue
+			}
+			sib := siblingPrefix(p)
+			if !set[sib] {
+				continue
+			}
+			delete(set, p)
+			delete(set, sib)
+			parent, _ := p.Addr().Prefix(bits - 1)
+			set[parent] = true
+		}
+	}
+
+	out := make([]netip.Prefix, 0, len(set))
+	for p := range set {
+		out = append(out, p)
+	}
+	sortPrefixes(out)
+	return out
+}
+
+// siblingPrefix returns the other half of p's parent.
+func siblingPrefix(p netip.Prefix) netip.Prefix {
+	addr := p.Addr().AsSlice()
+	bit := p.Bits() - 1
+	addr[bit/8] ^= 1 << (7 - uint(bit%8))
+	sib, _ := netip.AddrFromSlice(addr)
+	return netip.PrefixFrom(sib, p.Bits())
+}
+
+// sortPrefixes orders prefixes IPv4 first, then by address, then by length.
+func sortPrefixes(prefixes []netip.Prefix) {
+	sort.Slice(prefixes, func(i, j int) bool {
+		return comparePrefixes(prefixes[i], prefixes[j]) < 0
+	})
+}
+
+func comparePrefixes(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
@@ -0,0 +1,82 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestAggregatePrefixes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{"empty", nil, nil},
+		{"siblings", []string{"10.0.0.0/24", "10.0.1.0/24"}, []string{"10.0.0.0/23"}},
+		{"cascade", []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/23"}, []string{"10.0.0.0/22"}},
+		{"not siblings", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.1.0/24", "10.0.2.0/24"}},
+		{"covered", []string{"10.0.0.0/16", "10.0.5.0/24", "10.0.0.0/16"}, []string{"10.0.0.0/16"}},
+		{"covered then merged", []string{"10.0.0.0/17", "10.0.0.0/24", "10.0.128.0/17"}, []string{"10.0.0.0/16"}},
+		{"ipv6", []string{"2001:db8::/33", "2001:db8:8000::/33", "2001:db9::/48"}, []string{"2001:db8::/32", "2001:db9::/48"}},
+		{"mixed families", []string{"2001:db8::/32", "10.0.0.0/8"}, []string{"10.0.0.0/8", "2001:db8::/32"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var in, want []netip.Prefix
+			for _, p := range tc.in {
+				in = append(in, netip.MustParsePrefix(p))
+			}
+			for _, p := range tc.want {
+				want = append(want, netip.MustParsePrefix(p))
+			}
+			got := rib.AggregatePrefixes(in)
+			if len(got) == 0 && len(want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCIDRReport(t *testing.T) {
+	router := rib.GetNewRib()
+	deagg := &rib.RouteAttributes{AsPath: []uint32{3356, 64500}}
+	deaggTE := &rib.RouteAttributes{AsPath: []uint32{3356, 64500}, Communities: []uint32{1}}
+	tidy := &rib.RouteAttributes{AsPath: []uint32{174, 64501}}
+
+	// AS64500 announces a /22 as four /24s with identical attributes, plus a
+	// fifth /24 adjacent to them that differs only by community.
+	for _, p := range []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"} {
+		router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix(p), Attributes: deagg})
+	}
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.0.4.0/24"), Attributes: deaggTE})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.0.5.0/24"), Attributes: deaggTE})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("20.0.0.0/16"), Attributes: tidy})
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32"), Attributes: tidy})
+
+	report := router.CIDRReport()
+	if len(report) != 2 {
+		t.Fatalf("expected 2 origins, got %d", len(report))
+	}
+
+	top := report[0]
+	if top.Origin != 64500 || top.Prefixes != 6 || top.Saved() != 4 {
+		t.Errorf("unexpected top entry %+v (saved %d)", top, top.Saved())
+	}
+	want := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/22"), netip.MustParsePrefix("10.0.4.0/23")}
+	if !reflect.DeepEqual(top.Aggregates, want) {
+		t.Errorf("got aggregates %v, want %v", top.Aggregates, want)
+	}
+	if top.Factor() != 3 {
+		t.Errorf("expected deaggregation factor 3, got %.2f", top.Factor())
+	}
+
+	if report[1].Origin != 64501 || report[1].Saved() != 0 || len(report[1].Aggregates) != 2 {
+		t.Errorf("unexpected second entry %+v", report[1])
+	}
+}
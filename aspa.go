@@ -0,0 +1,274 @@
+package routing_table
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+)
+
+// ASPAState is the result of ASPA AS path verification
+// (draft-ietf-sidrops-aspa-verification).
+type ASPAState uint8
+
+const (
+	// ASPAValid means every hop of the path is attested as valid.
+	ASPAValid ASPAState = iota
+	// ASPAUnknown means the path cannot be proven valid or invalid because
+	// some ASes on it have no ASPA record.
+	ASPAUnknown
+	// ASPAInvalid means the path is a route leak or otherwise impossible
+	// given the attested provider sets.
+	ASPAInvalid
+)
+
+func (s ASPAState) String() string {
+	switch s {
+	case ASPAValid:
+		return "valid"
+	case ASPAUnknown:
+		return "unknown"
+	case ASPAInvalid:
+		return "invalid"
+	}
+	return fmt.Sprintf("ASPAState(%d)", uint8(s))
+}
+
+// ASPADirection selects the verification procedure, which depends on the
+// relationship with the neighbor the route was learned from.
+type ASPADirection uint8
+
+const (
+	// ASPAUpstream verifies routes learned from a customer, lateral peer or
+	// route server client: the whole path must climb from customer to provider.
+	ASPAUpstream ASPADirection = iota
+	// ASPADownstream verifies routes learned from a provider: the path may
+	// climb up, cross at most one peering, and descend down to us.
+	ASPADownstream
+)
+
+func (d ASPADirection) String() string {
+	switch d {
+	case ASPAUpstream:
+		return "upstream"
+	case ASPADownstream:
+		return "downstream"
+	}
+	return fmt.Sprintf("ASPADirection(%d)", uint8(d))
+}
+
+// ASPASet maps customer ASNs to the set of providers attested in their ASPA
+// records. A provider set of just AS0 declares that the customer has no
+// providers.
+//
+// A set must not be modified while it is being used for verification.
+type ASPASet struct {
+	providers map[uint32][]uint32
+}
+
+// NewASPASet returns an empty ASPA set.
+func NewASPASet() *ASPASet {
+	return &ASPASet{providers: make(map[uint32][]uint32)}
+}
+
+// Add records providers for customer, merging with any providers already
+// recorded for it.
+func (s *ASPASet) Add(customer uint32, providers []uint32) {
+	merged := append(s.providers[customer], providers...)
+	slices.Sort(merged)
+	s.providers[customer] = slices.Compact(merged)
+}
+
+// Len returns the number of customer ASNs with an ASPA record.
+func (s *ASPASet) Len() int {
+	return len(s.providers)
+}
+
+// Providers returns the attested providers of customer in ascending order,
+// and whether customer has an ASPA record at all.
+func (s *ASPASet) Providers(customer uint32) ([]uint32, bool) {
+	p, ok := s.providers[customer]
+	return p, ok
+}
+
+// aspaJSON is one entry of the "aspas" array. rpki-client writes
+// customer_asid with a providers list of numbers; Routinator writes customer
+// with providers as "AS65000" strings.
+type aspaJSON struct {
+	Customer     json.RawMessage   `json:"customer"`
+	CustomerASID json.RawMessage   `json:"customer_asid"`
+	Providers    []json.RawMessage `json:"providers"`
+}
+
+// LoadASPAs reads ASPA records from the JSON output of rpki-client (-j) or
+// Routinator (json or jsonext formats).
+func LoadASPAs(rd io.Reader) (*ASPASet, error) {
+	var file struct {
+		ASPAs []aspaJSON `json:"aspas"`
+	}
+	if err := json.NewDecoder(rd).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	s := NewASPASet()
+	for i, a := range file.ASPAs {
+		raw := a.Customer
+		if raw == nil {
+			raw = a.CustomerASID
+		}
+		customer, err := parseJSONASN(raw)
+		if err != nil {
+			return nil, fmt.Errorf("aspa %d: customer: %w", i, err)
+		}
+		providers := make([]uint32, 0, len(a.Providers))
+		for _, p := range a.Providers {
+			asn, err := parseJSONASN(p)
+			if err != nil {
+				return nil, fmt.Errorf("aspa %d: provider: %w", i, err)
+			}
+			providers = append(providers, asn)
+		}
+		s.Add(customer, providers)
+	}
+	return s, nil
+}
+
+// ASPAHop is one pair of adjacent ASes on a path: Customer's ASPA record was
+// checked for Provider.
+type ASPAHop struct {
+	Customer uint32
+	Provider uint32
+}
+
+func (h ASPAHop) String() string {
+	return fmt.Sprintf("AS%d -> AS%d", h.Customer, h.Provider)
+}
+
+// hopState is the outcome of checking one hop against the ASPA set.
+type hopState uint8
+
+const (
+	hopNoAttestation hopState = iota
+	hopProvider
+	hopNotProvider
+)
+
+func (s *ASPASet) authorized(customer, provider uint32) hopState {
+	providers, ok := s.providers[customer]
+	if !ok {
+		return hopNoAttestation
+	}
+	if _, found := slices.BinarySearch(providers, provider); found {
+		return hopProvider
+	}
+	return hopNotProvider
+}
+
+// Verify checks an AS path, neighbor first and origin last as in
+// RouteAttributes.AsPath, using the procedure for dir. For Invalid and
+// Unknown results the returned hop is the first one, counting from the
+// origin, that prevents the path from verifying. Prepends are collapsed, and
+// an empty path (a locally originated route) is Valid.
+func (s *ASPASet) Verify(path []uint32, dir ASPADirection) (ASPAState, ASPAHop) {
+	// as[0] is the origin and as[n-1] the neighbor, matching the draft's
+	// AS(1)..AS(N) numbering shifted by one.
+	as := make([]uint32, 0, len(path))
+	for i := len(path) - 1; i >= 0; i-- {
+		if len(as) == 0 || as[len(as)-1] != path[i] {
+			as = append(as, path[i])
+		}
+	}
+	n := len(as)
+
+	if dir == ASPAUpstream {
+		unknown := -1
+		for i := 0; i < n-1; i++ {
+			switch s.authorized(as[i], as[i+1]) {
+			case hopNotProvider:
+				return ASPAInvalid, ASPAHop{as[i], as[i+1]}
+			case hopNoAttestation:
+				if unknown < 0 {
+					unknown = i
+				}
+			}
+		}
+		if unknown >= 0 {
+			return ASPAUnknown, ASPAHop{as[unknown], as[unknown+1]}
+		}
+		return ASPAValid, ASPAHop{}
+	}
+
+	if n <= 2 {
+		return ASPAValid, ASPAHop{}
+	}
+
+	// Up-ramp: hops climbing from the origin towards the neighbor.
+	maxUp, minUp := n, n
+	for i := 0; i < n-1; i++ {
+		h := s.authorized(as[i], as[i+1])
+		if h != hopProvider && minUp == n {
+			minUp = i + 1
+		}
+		if h == hopNotProvider {
+			maxUp = i + 1
+			break
+		}
+	}
+	// Down-ramp: hops climbing from the neighbor back towards the origin.
+	maxDown, minDown := n, n
+	for j := n - 1; j >= 1; j-- {
+		h := s.authorized(as[j], as[j-1])
+		if h != hopProvider && minDown == n {
+			minDown = n - j
+		}
+		if h == hopNotProvider {
+			maxDown = n - j
+			break
+		}
+	}
+
+	if maxUp+maxDown < n {
+		return ASPAInvalid, ASPAHop{as[maxUp-1], as[maxUp]}
+	}
+	if minUp+minDown < n {
+		return ASPAUnknown, ASPAHop{as[minUp-1], as[minUp]}
+	}
+	return ASPAValid, ASPAHop{}
+}
+
+// ASPAResult is a path that failed ASPA verification.
+type ASPAResult struct {
+	Route Route
+	State ASPAState
+	Hop   ASPAHop
+}
+
+// VerifyASPA walks the entire RIB, verifying the AS path of every path
+// against s using the procedure for dir, and returns the Invalid and Unknown
+// paths, IPv4 before IPv6 in address order. The RIB does not know which
+// neighbor each path came from, so dir applies to all of them.
+func (r *Rib) VerifyASPA(s *ASPASet, dir ASPADirection) []ASPAResult {
+	var results []ASPAResult
+	verify := func(prefix netip.Prefix, n *node) {
+		for _, rt := range sortedRoutes(n, prefix) {
+			var path []uint32
+			if rt.Attributes != nil {
+				path = rt.Attributes.AsPath
+			}
+			if state, hop := s.Verify(path, dir); state != ASPAValid {
+				results = append(results, ASPAResult{Route: rt, State: state, Hop: hop})
+			}
+		}
+	}
+
+	r.v4mu.RLock()
+	r.walkIPv4(verify)
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(verify)
+	r.v6mu.RUnlock()
+
+	return results
+}
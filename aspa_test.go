@@ -0,0 +1,81 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+const aspaJSON = `{
+	"aspas": [
+		{"customer_asid": 64500, "expires": 1790000000, "providers": [3356]},
+		{"customer_asid": 3356, "providers": [0]},
+		{"customer": "AS174", "providers": ["AS0"]},
+		{"customer": "AS64510", "providers": ["AS174"]}
+	]
+}`
+
+func TestASPAVerify(t *testing.T) {
+	s, err := rib.LoadASPAs(strings.NewReader(aspaJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 4 {
+		t.Fatalf("expected 4 ASPA records, got %d", s.Len())
+	}
+	if p, ok := s.Providers(64510); !ok || len(p) != 1 || p[0] != 174 {
+		t.Errorf("unexpected providers for AS64510: %v", p)
+	}
+
+	tests := []struct {
+		name  string
+		path  []uint32
+		dir   rib.ASPADirection
+		state rib.ASPAState
+		hop   rib.ASPAHop
+	}{
+		{"customer route", []uint32{3356, 64500}, rib.ASPAUpstream, rib.ASPAValid, rib.ASPAHop{}},
+		{"prepended", []uint32{3356, 3356, 64500, 64500}, rib.ASPAUpstream, rib.ASPAValid, rib.ASPAHop{}},
+		{"not a provider", []uint32{64501, 64500}, rib.ASPAUpstream, rib.ASPAInvalid, rib.ASPAHop{Customer: 64500, Provider: 64501}},
+		{"leak through tier 1", []uint32{64999, 3356, 64500}, rib.ASPAUpstream, rib.ASPAInvalid, rib.ASPAHop{Customer: 3356, Provider: 64999}},
+		{"no attestation", []uint32{64998, 64999}, rib.ASPAUpstream, rib.ASPAUnknown, rib.ASPAHop{Customer: 64999, Provider: 64998}},
+		{"empty", nil, rib.ASPAUpstream, rib.ASPAValid, rib.ASPAHop{}},
+		{"up, peer, down", []uint32{64510, 174, 3356, 64500}, rib.ASPADownstream, rib.ASPAValid, rib.ASPAHop{}},
+		{"short", []uint32{64999, 64500}, rib.ASPADownstream, rib.ASPAValid, rib.ASPAHop{}},
+		{"leak", []uint32{174, 64510, 3356, 64500}, rib.ASPADownstream, rib.ASPAInvalid, rib.ASPAHop{Customer: 3356, Provider: 64510}},
+		{"unattested down", []uint32{64510, 64998, 64997}, rib.ASPADownstream, rib.ASPAUnknown, rib.ASPAHop{Customer: 64997, Provider: 64998}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			state, hop := s.Verify(tc.path, tc.dir)
+			if state != tc.state || hop != tc.hop {
+				t.Errorf("got %s at %s, want %s at %s", state, hop, tc.state, tc.hop)
+			}
+		})
+	}
+
+	if _, err := rib.LoadASPAs(strings.NewReader(`{"aspas": [{"customer": "ASx", "providers": []}]}`)); err == nil {
+		t.Error("expected error for invalid customer")
+	}
+}
+
+func TestRibVerifyASPA(t *testing.T) {
+	s, _ := rib.LoadASPAs(strings.NewReader(aspaJSON))
+	router := rib.GetNewRib()
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.0.0.0/24"), Attributes: &rib.RouteAttributes{AsPath: []uint32{64510, 174, 3356, 64500}}})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("2.0.0.0/24"), Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 64510, 3356, 64500}}})
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32"), Attributes: &rib.RouteAttributes{AsPath: []uint32{64510, 64998, 64997}}})
+
+	results := router.VerifyASPA(s, rib.ASPADownstream)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %v", results)
+	}
+	if results[0].Route.Prefix != netip.MustParsePrefix("2.0.0.0/24") || results[0].State != rib.ASPAInvalid || results[0].Hop.Provider != 64510 {
+		t.Errorf("unexpected first result %+v", results[0])
+	}
+	if results[1].Route.Prefix != netip.MustParsePrefix("2001:db8::/32") || results[1].State != rib.ASPAUnknown {
+		t.Errorf("unexpected second result %+v", results[1])
+	}
+}
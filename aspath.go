@@ -0,0 +1,870 @@
+package routing_table
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AsPathSyntax selects the operator dialect used by CompileAsPath.
+type AsPathSyntax int
+
+const (
+	// AsPathCisco is the Cisco/FRR "ip as-path access-list" dialect. The pattern
+	// is a character regular expression over the space-separated AS path, where
+	// "_" matches the start, the end, or the gap between two ASNs. Matching is
+	// unanchored unless "^" or "$" is used, e.g. "_3356_", "^65000_", "_[0-9]+$".
+	AsPathCisco AsPathSyntax = iota
+
+	// AsPathJunos is the Junos "as-path" dialect. Every term is a whole ASN:
+	// a number, a range "64512-65534", "." for any ASN or a set "[ 174 3356 ]".
+	// Terms take the usual "*", "+", "?" and "{m,n}" operators and may be grouped
+	// with "(...)" and "|". The pattern is matched against the full path,
+	// e.g. ".* 3356 .*" or "65000 .*".
+	AsPathJunos
+
+	// AsPathBird is the BIRD bgpmask dialect, e.g. "[= * 3356 * =]". "*" matches
+	// any number of ASNs, "?" exactly one ASN, "a..b" a range and "[a, b..c]"
+	// a set. The mask is matched against the full path.
+	AsPathBird
+)
+
+func (s AsPathSyntax) String() string {
+	switch s {
+	case AsPathCisco:
+		return "cisco"
+	case AsPathJunos:
+		return "junos"
+	case AsPathBird:
+		return "bird"
+	}
+	return "AsPathSyntax(" + strconv.Itoa(int(s)) + ")"
+}
+
+// AsPathMatcher is a compiled AS path pattern. It matches directly against an
+// AS path slice without formatting it as a string, and is safe for concurrent use.
+type AsPathMatcher struct {
+	expr   string
+	syntax AsPathSyntax
+	prog   []pathInst
+
+	// search is set for unanchored (Cisco) patterns: a new thread is started at
+	// every input position rather than only at the beginning.
+	search bool
+
+	machines sync.Pool
+}
+
+// CompileAsPath parses an AS path pattern in the given dialect and returns a
+// matcher that can be evaluated against []uint32 AS paths.
+func CompileAsPath(expr string, syntax AsPathSyntax) (*AsPathMatcher, error) {
+	var (
+		tree *pathNode
+		err  error
+	)
+	switch syntax {
+	case AsPathCisco:
+		tree, err = parseCiscoAsPath(expr)
+	case AsPathJunos:
+		tree, err = parseJunosAsPath(expr)
+	case AsPathBird:
+		tree, err = parseBirdAsPath(expr)
+	default:
+		return nil, fmt.Errorf("unknown AS path syntax %d", syntax)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s as-path %q: %w", syntax, expr, err)
+	}
+
+	c := &pathCompiler{}
+	if err := c.compile(tree); err != nil {
+		return nil, fmt.Errorf("%s as-path %q: %w", syntax, expr, err)
+	}
+	c.prog = append(c.prog, pathInst{op: opMatch})
+
+	m := &AsPathMatcher{
+		expr:   expr,
+		syntax: syntax,
+		prog:   c.prog,
+		search: syntax == AsPathCisco,
+	}
+	m.machines.New = func() any { return newPathMachine(len(m.prog)) }
+	return m, nil
+}
+
+// MustCompileAsPath is like CompileAsPath but panics if the pattern is invalid.
+func MustCompileAsPath(expr string, syntax AsPathSyntax) *AsPathMatcher {
+	m, err := CompileAsPath(expr, syntax)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// String returns the source pattern.
+func (m *AsPathMatcher) String() string {
+	return m.expr
+}
+
+// Syntax returns the dialect the pattern was compiled with.
+func (m *AsPathMatcher) Syntax() AsPathSyntax {
+	return m.syntax
+}
+
+// Match reports whether the AS path matches the pattern.
+func (m *AsPathMatcher) Match(path []uint32) bool {
+	vm := m.machines.Get().(*pathMachine)
+	defer m.machines.Put(vm)
+
+	input := path
+	if m.syntax == AsPathCisco {
+		// Cisco patterns operate on characters. Expand the path into its
+		// textual form in a reusable buffer instead of allocating a string.
+		vm.text = appendASPathText(vm.text[:0], path)
+		input = vm.text
+	}
+	return vm.run(m.prog, input, m.search)
+}
+
+// MatchAttributes reports whether the AS path of attr matches the pattern.
+// A nil attribute set is treated as an empty AS path.
+func (m *AsPathMatcher) MatchAttributes(attr *RouteAttributes) bool {
+	if attr == nil {
+		return m.Match(nil)
+	}
+	return m.Match(attr.AsPath)
+}
+
+// appendASPathText appends the space-separated decimal form of path to dst,
+// one symbol per character.
+func appendASPathText(dst []uint32, path []uint32) []uint32 {
+	var digits [10]byte
+	for i, asn := range path {
+		if i > 0 {
+			dst = append(dst, ' ')
+		}
+		for _, d := range strconv.AppendUint(digits[:0], uint64(asn), 10) {
+			dst = append(dst, uint32(d))
+		}
+	}
+	return dst
+}
+
+// PrefixesByAsPath walks the entire RIB and returns all IPv4 and IPv6 routes
+// whose AS path matches m. Unlike PrefixesByAsPathRegex, it avoids formatting
+// every path as a string and understands router-style AS path patterns.
+// Results are cached per interned attribute set, so each distinct AS path is
+// evaluated once per call; the cache is discarded when the walk ends and is
+// not shared between queries.
+func (r *Rib) PrefixesByAsPath(m *AsPathMatcher) (v4 []Route, v6 []Route) {
+	cache := make(map[*RouteAttributes]bool)
+	matches := func(attr *RouteAttributes) bool {
+		ok, seen := cache[attr]
+		if !seen {
+			ok = m.MatchAttributes(attr)
+			cache[attr] = ok
+		}
+		return ok
+	}
+
+	r.v4mu.RLock()
+	r.walkIPv4(func(prefix netip.Prefix, n *node) {
+		for id, attr := range n.paths {
+			if matches(attr) {
+				v4 = append(v4, n.route(prefix, id))
+			}
+		}
+	})
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(func(prefix netip.Prefix, n *node) {
+		for id, attr := range n.paths {
+			if matches(attr) {
+				v6 = append(v6, n.route(prefix, id))
+			}
+		}
+	})
+	r.v6mu.RUnlock()
+
+	return v4, v6
+}
+
+// pathNodeKind identifies a node in a parsed AS path pattern.
+type pathNodeKind uint8
+
+const (
+	pnEmpty pathNodeKind = iota
+	pnClass
+	pnConcat
+	pnAlt
+	pnRepeat
+	pnBegin
+	pnEnd
+)
+
+// maxPathRepeat bounds "{m,n}" counts so a pattern cannot expand into an
+// arbitrarily large program.
+const maxPathRepeat = 255
+
+// symRange is an inclusive range of input symbols. Symbols are characters for
+// the Cisco dialect and whole ASNs for the Junos and BIRD dialects.
+type symRange struct {
+	lo, hi uint32
+}
+
+// pathNode is the dialect-independent syntax tree all parsers produce.
+type pathNode struct {
+	kind     pathNodeKind
+	ranges   []symRange // pnClass
+	negate   bool       // pnClass
+	subs     []*pathNode
+	min, max int // pnRepeat; max < 0 means unbounded
+}
+
+func classNode(negate bool, ranges ...symRange) *pathNode {
+	return &pathNode{kind: pnClass, ranges: ranges, negate: negate}
+}
+
+func anySymbol() *pathNode {
+	return classNode(false, symRange{0, ^uint32(0)})
+}
+
+func concatNode(subs ...*pathNode) *pathNode {
+	return &pathNode{kind: pnConcat, subs: subs}
+}
+
+func repeatNode(sub *pathNode, min, max int) *pathNode {
+	return &pathNode{kind: pnRepeat, subs: []*pathNode{sub}, min: min, max: max}
+}
+
+// pathParser holds the shared scanning state for the three dialect parsers.
+type pathParser struct {
+	src string
+	pos int
+}
+
+func (p *pathParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *pathParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *pathParser) skipSpace() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *pathParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// number scans an unsigned 32-bit decimal number.
+func (p *pathParser) number() (uint32, error) {
+	start := p.pos
+	for !p.eof() && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, p.errorf("expected number")
+	}
+	n, err := strconv.ParseUint(p.src[start:p.pos], 10, 32)
+	if err != nil {
+		return 0, p.errorf("invalid ASN %q", p.src[start:p.pos])
+	}
+	return uint32(n), nil
+}
+
+// quantifier parses an optional "*", "+", "?" or "{m,n}" suffix and wraps sub.
+func (p *pathParser) quantifier(sub *pathNode) (*pathNode, error) {
+	for !p.eof() {
+		switch p.peek() {
+		case '*':
+			p.pos++
+			sub = repeatNode(sub, 0, -1)
+		case '+':
+			p.pos++
+			sub = repeatNode(sub, 1, -1)
+		case '?':
+			p.pos++
+			sub = repeatNode(sub, 0, 1)
+		case '{':
+			p.pos++
+			min, max, err := p.bounds()
+			if err != nil {
+				return nil, err
+			}
+			sub = repeatNode(sub, min, max)
+		default:
+			return sub, nil
+		}
+	}
+	return sub, nil
+}
+
+// bounds parses the inside of "{m}", "{m,}" or "{m,n}" up to and including "}".
+func (p *pathParser) bounds() (int, int, error) {
+	lo, err := p.number()
+	if err != nil {
+		return 0, 0, err
+	}
+	min, max := int(lo), int(lo)
+	if p.peek() == ',' {
+		p.pos++
+		if p.peek() == '}' {
+			max = -1
+		} else {
+			hi, err := p.number()
+			if err != nil {
+				return 0, 0, err
+			}
+			max = int(hi)
+		}
+	}
+	if p.peek() != '}' {
+		return 0, 0, p.errorf("missing '}'")
+	}
+	p.pos++
+	if min > maxPathRepeat || max > maxPathRepeat || (max >= 0 && max < min) {
+		return 0, 0, p.errorf("invalid repeat count {%d,%d}", min, max)
+	}
+	return min, max, nil
+}
+
+// parseCiscoAsPath parses a Cisco-style character regular expression.
+func parseCiscoAsPath(expr string) (*pathNode, error) {
+	p := &pathParser{src: expr}
+	n, err := p.ciscoAlt()
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return n, nil
+}
+
+func (p *pathParser) ciscoAlt() (*pathNode, error) {
+	var alts []*pathNode
+	for {
+		n, err := p.ciscoConcat()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, n)
+		if p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return &pathNode{kind: pnAlt, subs: alts}, nil
+}
+
+func (p *pathParser) ciscoConcat() (*pathNode, error) {
+	var seq []*pathNode
+	for !p.eof() && p.peek() != '|' && p.peek() != ')' {
+		atom, err := p.ciscoAtom()
+		if err != nil {
+			return nil, err
+		}
+		atom, err = p.quantifier(atom)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, atom)
+	}
+	return concatNode(seq...), nil
+}
+
+func (p *pathParser) ciscoAtom() (*pathNode, error) {
+	c := p.peek()
+	p.pos++
+	switch c {
+	case '(':
+		n, err := p.ciscoAlt()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("missing ')'")
+		}
+		p.pos++
+		return n, nil
+	case '^':
+		return &pathNode{kind: pnBegin}, nil
+	case '$':
+		return &pathNode{kind: pnEnd}, nil
+	case '_':
+		// "_" matches any AS path delimiter, including the start and end of the path.
+		return &pathNode{kind: pnAlt, subs: []*pathNode{
+			{kind: pnBegin},
+			{kind: pnEnd},
+			classNode(false, symRange{' ', ' '}, symRange{',', ','}, symRange{'(', ')'}, symRange{'{', '{'}, symRange{'}', '}'}),
+		}}, nil
+	case '.':
+		return anySymbol(), nil
+	case '[':
+		return p.ciscoClass()
+	case '\\':
+		if p.eof() {
+			return nil, p.errorf("trailing backslash")
+		}
+		c = p.peek()
+		p.pos++
+		return classNode(false, symRange{uint32(c), uint32(c)}), nil
+	case '*', '+', '?', '{':
+		p.pos--
+		return nil, p.errorf("missing argument to repetition operator %q", c)
+	}
+	return classNode(false, symRange{uint32(c), uint32(c)}), nil
+}
+
+// ciscoClass parses a bracket expression such as "[0-9]" or "[^1-3]" after
+// the opening '['.
+func (p *pathParser) ciscoClass() (*pathNode, error) {
+	negate := false
+	if p.peek() == '^' {
+		negate = true
+		p.pos++
+	}
+	var ranges []symRange
+	for {
+		if p.eof() {
+			return nil, p.errorf("missing ']'")
+		}
+		c := p.peek()
+		if c == ']' && len(ranges) > 0 {
+			p.pos++
+			break
+		}
+		p.pos++
+		lo := uint32(c)
+		hi := lo
+		if p.peek() == '-' && p.pos+1 < len(p.src) && p.src[p.pos+1] != ']' {
+			hi = uint32(p.src[p.pos+1])
+			p.pos += 2
+			if hi < lo {
+				return nil, p.errorf("invalid character range %c-%c", lo, hi)
+			}
+		}
+		ranges = append(ranges, symRange{lo, hi})
+	}
+	return classNode(negate, ranges...), nil
+}
+
+// parseJunosAsPath parses a Junos-style AS-level regular expression. The
+// result is anchored at both ends.
+func parseJunosAsPath(expr string) (*pathNode, error) {
+	p := &pathParser{src: expr}
+	n, err := p.junosAlt()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return concatNode(&pathNode{kind: pnBegin}, n, &pathNode{kind: pnEnd}), nil
+}
+
+func (p *pathParser) junosAlt() (*pathNode, error) {
+	var alts []*pathNode
+	for {
+		n, err := p.junosConcat()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, n)
+		p.skipSpace()
+		if p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return &pathNode{kind: pnAlt, subs: alts}, nil
+}
+
+func (p *pathParser) junosConcat() (*pathNode, error) {
+	var seq []*pathNode
+	for {
+		p.skipSpace()
+		if p.eof() || p.peek() == '|' || p.peek() == ')' {
+			break
+		}
+		term, err := p.junosTerm()
+		if err != nil {
+			return nil, err
+		}
+		term, err = p.quantifier(term)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, term)
+	}
+	return concatNode(seq...), nil
+}
+
+func (p *pathParser) junosTerm() (*pathNode, error) {
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		n, err := p.junosAlt()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.peek() != ')' {
+			return nil, p.errorf("missing ')'")
+		}
+		p.pos++
+		return n, nil
+	case c == '^':
+		p.pos++
+		return &pathNode{kind: pnEmpty}, nil
+	case c == '$':
+		p.pos++
+		return &pathNode{kind: pnEmpty}, nil
+	case c == '.':
+		p.pos++
+		return anySymbol(), nil
+	case c == '[':
+		p.pos++
+		return p.junosSet()
+	case c >= '0' && c <= '9':
+		r, err := p.asnRange("-")
+		if err != nil {
+			return nil, err
+		}
+		return classNode(false, r), nil
+	}
+	return nil, p.errorf("unexpected %q", p.peek())
+}
+
+// junosSet parses "[ 174 3356 64512-65534 ]" or "[^ ... ]" after the '['.
+func (p *pathParser) junosSet() (*pathNode, error) {
+	negate := false
+	if p.peek() == '^' {
+		negate = true
+		p.pos++
+	}
+	var ranges []symRange
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, p.errorf("missing ']'")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			break
+		}
+		r, err := p.asnRange("-")
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		return nil, p.errorf("empty AS set")
+	}
+	return classNode(negate, ranges...), nil
+}
+
+// asnRange parses "a" or "a<sep>b" as an inclusive ASN range.
+func (p *pathParser) asnRange(sep string) (symRange, error) {
+	lo, err := p.number()
+	if err != nil {
+		return symRange{}, err
+	}
+	if !strings.HasPrefix(p.src[p.pos:], sep) {
+		return symRange{lo, lo}, nil
+	}
+	p.pos += len(sep)
+	hi, err := p.number()
+	if err != nil {
+		return symRange{}, err
+	}
+	if hi < lo {
+		return symRange{}, p.errorf("invalid ASN range %d%s%d", lo, sep, hi)
+	}
+	return symRange{lo, hi}, nil
+}
+
+// parseBirdAsPath parses a BIRD bgpmask such as "[= * 3356 * =]".
+func parseBirdAsPath(expr string) (*pathNode, error) {
+	body := strings.TrimSpace(expr)
+	if !strings.HasPrefix(body, "[=") || !strings.HasSuffix(body, "=]") || len(body) < 4 {
+		return nil, fmt.Errorf("bgpmask must be enclosed in [= ... =]")
+	}
+	p := &pathParser{src: body[2 : len(body)-2]}
+
+	seq := []*pathNode{{kind: pnBegin}}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		switch c := p.peek(); {
+		case c == '*':
+			p.pos++
+			seq = append(seq, repeatNode(anySymbol(), 0, -1))
+		case c == '?':
+			p.pos++
+			seq = append(seq, anySymbol())
+		case c == '[':
+			p.pos++
+			set, err := p.birdSet()
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, set)
+		case c >= '0' && c <= '9':
+			r, err := p.asnRange("..")
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, classNode(false, r))
+		default:
+			return nil, p.errorf("unexpected %q", c)
+		}
+	}
+	seq = append(seq, &pathNode{kind: pnEnd})
+	return concatNode(seq...), nil
+}
+
+// birdSet parses "[1, 2, 64512..65534]" after the '['.
+func (p *pathParser) birdSet() (*pathNode, error) {
+	var ranges []symRange
+	for {
+		p.skipSpace()
+		r, err := p.asnRange("..")
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return classNode(false, ranges...), nil
+		default:
+			return nil, p.errorf("missing ']'")
+		}
+	}
+}
+
+// pathOp is a virtual machine instruction opcode.
+type pathOp uint8
+
+const (
+	opMatch pathOp = iota
+	opSym          // consume one symbol contained in ranges (or not, if negate)
+	opSplit        // continue at both x and y
+	opJmp          // continue at x
+	opBegin        // assert start of input
+	opEnd          // assert end of input
+)
+
+// maxPathProg bounds the compiled program size.
+const maxPathProg = 10000
+
+type pathInst struct {
+	op     pathOp
+	x, y   int
+	ranges []symRange
+	negate bool
+}
+
+func (in *pathInst) matches(sym uint32) bool {
+	for _, r := range in.ranges {
+		if sym >= r.lo && sym <= r.hi {
+			return !in.negate
+		}
+	}
+	return in.negate
+}
+
+type pathCompiler struct {
+	prog []pathInst
+}
+
+func (c *pathCompiler) emit(in pathInst) int {
+	c.prog = append(c.prog, in)
+	return len(c.prog) - 1
+}
+
+// compile appends the instructions for n. Control falls through to the next
+// instruction on success.
+func (c *pathCompiler) compile(n *pathNode) error {
+	if len(c.prog) > maxPathProg {
+		return fmt.Errorf("pattern too large")
+	}
+	switch n.kind {
+	case pnEmpty:
+	case pnClass:
+		c.emit(pathInst{op: opSym, ranges: n.ranges, negate: n.negate})
+	case pnBegin:
+		c.emit(pathInst{op: opBegin})
+	case pnEnd:
+		c.emit(pathInst{op: opEnd})
+	case pnConcat:
+		for _, sub := range n.subs {
+			if err := c.compile(sub); err != nil {
+				return err
+			}
+		}
+	case pnAlt:
+		// split L1, next; L1: a; jmp end; next: split L2, next; ... last
+		var jumps []int
+		for i, sub := range n.subs {
+			if i == len(n.subs)-1 {
+				if err := c.compile(sub); err != nil {
+					return err
+				}
+				break
+			}
+			split := c.emit(pathInst{op: opSplit})
+			c.prog[split].x = len(c.prog)
+			if err := c.compile(sub); err != nil {
+				return err
+			}
+			jumps = append(jumps, c.emit(pathInst{op: opJmp}))
+			c.prog[split].y = len(c.prog)
+		}
+		for _, j := range jumps {
+			c.prog[j].x = len(c.prog)
+		}
+	case pnRepeat:
+		sub := n.subs[0]
+		for i := 0; i < n.min; i++ {
+			if err := c.compile(sub); err != nil {
+				return err
+			}
+		}
+		if n.max < 0 {
+			// L1: split L2, L3; L2: sub; jmp L1; L3:
+			split := c.emit(pathInst{op: opSplit})
+			c.prog[split].x = len(c.prog)
+			if err := c.compile(sub); err != nil {
+				return err
+			}
+			c.emit(pathInst{op: opJmp, x: split})
+			c.prog[split].y = len(c.prog)
+			return nil
+		}
+		// Each optional copy may be skipped straight to the end.
+		var splits []int
+		for i := n.min; i < n.max; i++ {
+			split := c.emit(pathInst{op: opSplit})
+			c.prog[split].x = len(c.prog)
+			splits = append(splits, split)
+			if err := c.compile(sub); err != nil {
+				return err
+			}
+		}
+		for _, s := range splits {
+			c.prog[s].y = len(c.prog)
+		}
+	}
+	return nil
+}
+
+// pathMachine is the scratch state of a Pike VM run. Machines are pooled per
+// matcher so matching does not allocate in the steady state.
+type pathMachine struct {
+	clist, nlist threadSet
+	text         []uint32
+}
+
+func newPathMachine(n int) *pathMachine {
+	return &pathMachine{clist: newThreadSet(n), nlist: newThreadSet(n)}
+}
+
+// threadSet is a sparse set of program counters.
+type threadSet struct {
+	sparse []int
+	dense  []int
+}
+
+func newThreadSet(n int) threadSet {
+	return threadSet{sparse: make([]int, n), dense: make([]int, 0, n)}
+}
+
+func (s *threadSet) contains(pc int) bool {
+	i := s.sparse[pc]
+	return i < len(s.dense) && s.dense[i] == pc
+}
+
+func (s *threadSet) add(pc int) {
+	s.sparse[pc] = len(s.dense)
+	s.dense = append(s.dense, pc)
+}
+
+func (s *threadSet) clear() {
+	s.dense = s.dense[:0]
+}
+
+// run executes prog over input and reports whether it reaches opMatch.
+func (vm *pathMachine) run(prog []pathInst, input []uint32, search bool) bool {
+	vm.clist.clear()
+	vm.nlist.clear()
+	if vm.addThread(&vm.clist, prog, 0, 0, len(input)) {
+		return true
+	}
+	for pos := 0; pos < len(input); pos++ {
+		if len(vm.clist.dense) == 0 && !search {
+			return false
+		}
+		sym := input[pos]
+		for _, pc := range vm.clist.dense {
+			in := &prog[pc]
+			if in.op == opSym && in.matches(sym) {
+				if vm.addThread(&vm.nlist, prog, pc+1, pos+1, len(input)) {
+					return true
+				}
+			}
+		}
+		if search && vm.addThread(&vm.nlist, prog, 0, pos+1, len(input)) {
+			return true
+		}
+		vm.clist, vm.nlist = vm.nlist, vm.clist
+		vm.nlist.clear()
+	}
+	return false
+}
+
+// addThread follows empty transitions from pc and reports whether opMatch is reachable.
+func (vm *pathMachine) addThread(q *threadSet, prog []pathInst, pc, pos, end int) bool {
+	if q.contains(pc) {
+		return false
+	}
+	q.add(pc)
+	switch in := &prog[pc]; in.op {
+	case opMatch:
+		return true
+	case opJmp:
+		return vm.addThread(q, prog, in.x, pos, end)
+	case opSplit:
+		return vm.addThread(q, prog, in.x, pos, end) || vm.addThread(q, prog, in.y, pos, end)
+	case opBegin:
+		return pos == 0 && vm.addThread(q, prog, pc+1, pos, end)
+	case opEnd:
+		return pos == end && vm.addThread(q, prog, pc+1, pos, end)
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestAsPathMatcher(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		syntax rib.AsPathSyntax
+		path   []uint32
+		want   bool
+	}{
+		// Cisco: character regex with "_" delimiters, unanchored.
+		{"cisco transit hit", "_3356_", rib.AsPathCisco, []uint32{65000, 3356, 15169}, true},
+		{"cisco transit as origin", "_3356_", rib.AsPathCisco, []uint32{65000, 3356}, true},
+		{"cisco no partial asn", "_3356_", rib.AsPathCisco, []uint32{65000, 33561}, false},
+		{"cisco unbounded substring", "335", rib.AsPathCisco, []uint32{65000, 33561}, true},
+		{"cisco first asn", "^65000_", rib.AsPathCisco, []uint32{65000, 3356}, true},
+		{"cisco first asn miss", "^65000_", rib.AsPathCisco, []uint32{3356, 65000}, false},
+		{"cisco any origin", "_[0-9]+$", rib.AsPathCisco, []uint32{65000}, true},
+		{"cisco empty path", "^$", rib.AsPathCisco, nil, true},
+		{"cisco empty path miss", "^$", rib.AsPathCisco, []uint32{1}, false},
+		{"cisco private range", "_6451[2-9]_", rib.AsPathCisco, []uint32{1, 64513, 2}, true},
+		{"cisco alternation", "^(174|1299)_", rib.AsPathCisco, []uint32{1299, 2}, true},
+		{"cisco prepend", "_(65000_)+65000$", rib.AsPathCisco, []uint32{1, 65000, 65000, 65000}, true},
+
+		// Junos: AS-level regex anchored to the full path.
+		{"junos anywhere", ".* 3356 .*", rib.AsPathJunos, []uint32{3356}, true},
+		{"junos anywhere miss", ".* 3356 .*", rib.AsPathJunos, []uint32{33560}, false},
+		{"junos first", "65000 .*", rib.AsPathJunos, []uint32{65000, 1, 2}, true},
+		{"junos anchored", "65000", rib.AsPathJunos, []uint32{65000, 1}, false},
+		{"junos range", ".* 64512-65534", rib.AsPathJunos, []uint32{1, 64999}, true},
+		{"junos set", "[ 174 1299 ] .*", rib.AsPathJunos, []uint32{1299, 5}, true},
+		{"junos negated set", "[^ 174 1299 ] .*", rib.AsPathJunos, []uint32{1299, 5}, false},
+		{"junos length", ".{2,3}", rib.AsPathJunos, []uint32{1, 2, 3, 4}, false},
+		{"junos alternation", "(174 | 1299) .*", rib.AsPathJunos, []uint32{174}, true},
+
+		// BIRD bgpmask.
+		{"bird anywhere", "[= * 3356 * =]", rib.AsPathBird, []uint32{1, 3356, 2}, true},
+		{"bird anywhere miss", "[= * 3356 * =]", rib.AsPathBird, []uint32{1, 2}, false},
+		{"bird single", "[= ? 3356 =]", rib.AsPathBird, []uint32{1, 3356}, true},
+		{"bird single miss", "[= ? 3356 =]", rib.AsPathBird, []uint32{1, 2, 3356}, false},
+		{"bird range", "[= * 64512..65534 =]", rib.AsPathBird, []uint32{1, 65000}, true},
+		{"bird set", "[= [174, 1299] * =]", rib.AsPathBird, []uint32{174, 1}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := rib.CompileAsPath(tc.expr, tc.syntax)
+			if err != nil {
+				t.Fatalf("CompileAsPath(%q): %v", tc.expr, err)
+			}
+			if got := m.Match(tc.path); got != tc.want {
+				t.Errorf("%q.Match(%v) = %v, want %v", tc.expr, tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAsPathCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr   string
+		syntax rib.AsPathSyntax
+	}{
+		{"(3356", rib.AsPathCisco},
+		{"[0-9", rib.AsPathCisco},
+		{"*3356", rib.AsPathCisco},
+		{"3356 {2,1}", rib.AsPathJunos},
+		{"65535-1", rib.AsPathJunos},
+		{"4294967296", rib.AsPathJunos},
+		{"* 3356 *", rib.AsPathBird},
+		{"[= 3356 foo =]", rib.AsPathBird},
+	}
+	for _, tc := range tests {
+		if _, err := rib.CompileAsPath(tc.expr, tc.syntax); err == nil {
+			t.Errorf("expected error compiling %s pattern %q", tc.syntax, tc.expr)
+		}
+	}
+}
+
+func TestPrefixesByAsPath(t *testing.T) {
+	router := rib.GetNewRib()
+	via3356 := &rib.RouteAttributes{AsPath: []uint32{65000, 3356, 15169}}
+	via174 := &rib.RouteAttributes{AsPath: []uint32{65000, 174, 15169}}
+
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("8.8.8.0/24"), Attributes: via3356})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("8.8.4.0/24"), Attributes: via3356})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("8.8.4.0/24"), Attributes: via174, PathID: 1})
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:4860::/32"), Attributes: via174})
+
+	v4, v6 := router.PrefixesByAsPath(rib.MustCompileAsPath("_3356_", rib.AsPathCisco))
+	if len(v4) != 2 || len(v6) != 0 {
+		t.Fatalf("expected 2 v4 and 0 v6 routes via 3356, got %d and %d", len(v4), len(v6))
+	}
+	for _, rt := range v4 {
+		if rt.PathID != 0 {
+			t.Errorf("unexpected path %d for %s", rt.PathID, rt.Prefix)
+		}
+	}
+
+	v4, v6 = router.PrefixesByAsPath(rib.MustCompileAsPath("[= * 174 * =]", rib.AsPathBird))
+	if len(v4) != 1 || v4[0].PathID != 1 || v4[0].Prefix != netip.MustParsePrefix("8.8.4.0/24") {
+		t.Errorf("expected 8.8.4.0/24 path 1 via 174, got %v", v4)
+	}
+	if len(v6) != 1 {
+		t.Errorf("expected 1 v6 route via 174, got %d", len(v6))
+	}
+}
@@ -0,0 +1,129 @@
+package routing_table
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+)
+
+// batchChunk is the minimum number of addresses handed to one goroutine in
+// SearchBatch. Smaller batches are not worth the scheduling overhead.
+const batchChunk = 4096
+
+// SearchBatch performs a longest prefix match for every address in addrs and
+// stores the result in results at the same index. Addresses with no matching
+// route, outside 2000::/3, or invalid leave a zero Route, which can be detected
+// with !results[i].Prefix.IsValid(). IPv4 and IPv6 addresses may be mixed.
+//
+// Each address family lock is taken once for the whole batch. Addresses are
+// processed grouped by their root array slot so that consecutive lookups walk
+// the same part of the trie. With workers > 1 the batch is split across that
+// many goroutines.
+//
+// results must be at least as long as addrs.
+func (r *Rib) SearchBatch(addrs []netip.Addr, results []Route, workers int) {
+	if len(results) < len(addrs) {
+		panic(fmt.Sprintf("SearchBatch: results has length %d, need %d", len(results), len(addrs)))
+	}
+	order := groupBySlot(addrs)
+
+	r.v4mu.RLock()
+	defer r.v4mu.RUnlock()
+	r.v6mu.RLock()
+	defer r.v6mu.RUnlock()
+
+	search := func(idx []int32) {
+		for _, i := range idx {
+			ip := addrs[i]
+			var (
+				attr *RouteAttributes
+				bits int
+			)
+			if ip.Is4() {
+				attr, bits = r.searchIPv4Unlocked(ip)
+			} else {
+				attr, bits = r.searchIPv6Unlocked(ip)
+			}
+			if attr == nil {
+				results[i] = Route{}
+				continue
+			}
+			results[i] = Route{Prefix: netip.PrefixFrom(ip, bits).Masked(), Attributes: attr}
+		}
+	}
+
+	// Addresses that cannot match are left out of order; clear their results.
+	for i := range addrs {
+		if !addrs[i].Is4() && !isGlobalUnicastV6(addrs[i]) {
+			results[i] = Route{}
+		}
+	}
+
+	if workers > len(order)/batchChunk {
+		workers = len(order) / batchChunk
+	}
+	if workers <= 1 {
+		search(order)
+		return
+	}
+
+	// Split the slot-grouped order so each goroutine works on a contiguous
+	// range of the trie. Both family locks are already held for all of them.
+	var wg sync.WaitGroup
+	per := (len(order) + workers - 1) / workers
+	for start := 0; start < len(order); start += per {
+		end := min(start+per, len(order))
+		wg.Add(1)
+		go func(idx []int32) {
+			defer wg.Done()
+			search(idx)
+		}(order[start:end])
+	}
+	wg.Wait()
+}
+
+// isGlobalUnicastV6 reports whether ip is an IPv6 address inside 2000::/3,
+// the only IPv6 space the RIB stores.
+func isGlobalUnicastV6(ip netip.Addr) bool {
+	if !ip.Is6() {
+		return false
+	}
+	b := ip.As16()[0]
+	return b >= 0x20 && b <= 0x3F
+}
+
+// groupBySlot returns the indexes of addrs ordered by root array slot: IPv4
+// by first octet, followed by IPv6 by (first byte - 0x20). It is a counting
+// sort, so it runs in linear time. Addresses that cannot match any route are
+// left out.
+func groupBySlot(addrs []netip.Addr) []int32 {
+	const slots = 256 + 32
+	slotOf := func(ip netip.Addr) int {
+		switch {
+		case ip.Is4():
+			return int(ip.As4()[0])
+		case isGlobalUnicastV6(ip):
+			return 256 + int(ip.As16()[0]-0x20)
+		}
+		return -1
+	}
+
+	var counts [slots + 1]int
+	for _, ip := range addrs {
+		if s := slotOf(ip); s >= 0 {
+			counts[s+1]++
+		}
+	}
+	for s := 1; s <= slots; s++ {
+		counts[s] += counts[s-1]
+	}
+
+	order := make([]int32, counts[slots])
+	for i, ip := range addrs {
+		if s := slotOf(ip); s >= 0 {
+			order[counts[s]] = int32(i)
+			counts[s]++
+		}
+	}
+	return order
+}
@@ -0,0 +1,71 @@
+package routing_table_test
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+// TestSearchBatch verifies that batch lookups agree with SearchIPv4/SearchIPv6
+// for a mixed-family batch, both sequentially and fanned out.
+func TestSearchBatch(t *testing.T) {
+	router := rib.GetNewRib()
+	for _, p := range []string{"1.0.0.0/8", "1.1.0.0/16", "1.1.1.0/24", "10.0.0.0/8", "200.1.0.0/16"} {
+		router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix(p), Attributes: &rib.RouteAttributes{LocalPref: 100}})
+	}
+	for _, p := range []string{"2001:db8::/32", "2001:db8:1::/48", "2a00::/12"} {
+		router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix(p), Attributes: &rib.RouteAttributes{LocalPref: 100}})
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	addrs := []netip.Addr{
+		netip.MustParseAddr("1.1.1.1"),
+		netip.MustParseAddr("fc00::1"),
+		netip.MustParseAddr("2001:db8:1::1"),
+		{},
+	}
+	for i := 0; i < 50000; i++ {
+		if i%2 == 0 {
+			addrs = append(addrs, netip.AddrFrom4([4]byte{[]byte{1, 10, 200, 9}[i%4], byte(rng.Intn(4)), byte(rng.Intn(4)), 1}))
+		} else {
+			addrs = append(addrs, netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 0, byte(rng.Intn(3))}))
+		}
+	}
+
+	for _, workers := range []int{1, 4} {
+		results := make([]rib.Route, len(addrs))
+		router.SearchBatch(addrs, results, workers)
+
+		for i, ip := range addrs {
+			var want *rib.Route
+			switch {
+			case ip.Is4():
+				want = router.SearchIPv4(ip)
+			case ip.Is6():
+				want = router.SearchIPv6(ip)
+			}
+			got := results[i]
+			if want == nil {
+				if got.Prefix.IsValid() {
+					t.Fatalf("workers=%d: %s expected no match, got %s", workers, ip, got.Prefix)
+				}
+				continue
+			}
+			if got.Prefix != want.Prefix || got.Attributes != want.Attributes {
+				t.Fatalf("workers=%d: %s got %s, want %s", workers, ip, got.Prefix, want.Prefix)
+			}
+		}
+	}
+}
+
+func TestSearchBatchShortResults(t *testing.T) {
+	router := rib.GetNewRib()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for short results slice")
+		}
+	}()
+	router.SearchBatch(make([]netip.Addr, 2), make([]rib.Route, 1), 1)
+}
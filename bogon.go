@@ -0,0 +1,285 @@
+package routing_table
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// ASNRange is an inclusive range of AS numbers.
+type ASNRange struct {
+	First  uint32
+	Last   uint32
+	Reason string
+}
+
+// BogonList holds prefixes and ASNs that should never appear in the global
+// routing table. A prefix is a bogon when it lies within any listed prefix.
+//
+// A list must not be modified once it is installed on a RIB with
+// SetBogonFilter; build a new list and install that instead.
+type BogonList struct {
+	prefixes *PrefixList
+	reasons  map[netip.Prefix]string
+	asns     []ASNRange
+}
+
+// NewBogonList returns an empty bogon list.
+func NewBogonList() *BogonList {
+	return &BogonList{
+		prefixes: NewPrefixList("bogons"),
+		reasons:  make(map[netip.Prefix]string),
+	}
+}
+
+// DefaultBogons returns a new list holding the reserved and special-purpose
+// IPv4 and IPv6 prefixes and the reserved ASN ranges from the IANA registries.
+// The caller may add to it before use.
+func DefaultBogons() *BogonList {
+	b := NewBogonList()
+	for _, e := range defaultBogonPrefixes {
+		b.AddPrefix(netip.MustParsePrefix(e.prefix), e.reason)
+	}
+	for _, r := range defaultBogonASNs {
+		b.AddASNRange(r.First, r.Last, r.Reason)
+	}
+	return b
+}
+
+var defaultBogonPrefixes = []struct {
+	prefix string
+	reason string
+}{
+	{"0.0.0.0/8", "this network (RFC 791)"},
+	{"10.0.0.0/8", "private-use (RFC 1918)"},
+	{"100.64.0.0/10", "shared address space (RFC 6598)"},
+	{"127.0.0.0/8", "loopback (RFC 1122)"},
+	{"169.254.0.0/16", "link-local (RFC 3927)"},
+	{"172.16.0.0/12", "private-use (RFC 1918)"},
+	{"192.0.0.0/24", "IETF protocol assignments (RFC 6890)"},
+	{"192.0.2.0/24", "documentation (RFC 5737)"},
+	{"192.88.99.0/24", "deprecated 6to4 relay anycast (RFC 7526)"},
+	{"192.168.0.0/16", "private-use (RFC 1918)"},
+	{"198.18.0.0/15", "benchmarking (RFC 2544)"},
+	{"198.51.100.0/24", "documentation (RFC 5737)"},
+	{"203.0.113.0/24", "documentation (RFC 5737)"},
+	{"224.0.0.0/4", "multicast (RFC 5771)"},
+	{"240.0.0.0/4", "reserved (RFC 1112)"},
+
+	{"::/8", "reserved by IETF (RFC 4291)"},
+	{"100::/64", "discard-only (RFC 6666)"},
+	{"2001:2::/48", "benchmarking (RFC 5180)"},
+	{"2001:10::/28", "ORCHID (RFC 4843)"},
+	{"2001:db8::/32", "documentation (RFC 3849)"},
+	{"2002::/16", "6to4 (RFC 3056)"},
+	{"3ffe::/16", "6bone (RFC 3701)"},
+	{"3fff::/20", "documentation (RFC 9637)"},
+	{"5f00::/16", "SRv6 SIDs (RFC 9602)"},
+	{"fc00::/7", "unique local (RFC 4193)"},
+	{"fe80::/10", "link-local (RFC 4291)"},
+	{"fec0::/10", "site-local (RFC 3879)"},
+	{"ff00::/8", "multicast (RFC 4291)"},
+}
+
+var defaultBogonASNs = []ASNRange{
+	{0, 0, "reserved (RFC 7607)"},
+	{23456, 23456, "AS_TRANS (RFC 6793)"},
+	{64496, 64511, "documentation (RFC 5398)"},
+	{64512, 65534, "private use (RFC 6996)"},
+	{65535, 65535, "reserved (RFC 7300)"},
+	{65536, 65551, "documentation (RFC 5398)"},
+	{65552, 131071, "reserved by IANA"},
+	{4200000000, 4294967294, "private use (RFC 6996)"},
+	{4294967295, 4294967295, "reserved (RFC 7300)"},
+}
+
+// AddPrefix adds a bogon prefix with a human-readable reason.
+func (b *BogonList) AddPrefix(p netip.Prefix, reason string) error {
+	if !p.IsValid() {
+		return fmt.Errorf("invalid prefix")
+	}
+	p = p.Masked()
+	if _, ok := b.reasons[p]; ok {
+		b.reasons[p] = reason
+		return nil
+	}
+	if err := b.prefixes.Add(PrefixListEntry{Prefix: p, MinLen: p.Bits(), MaxLen: p.Addr().BitLen()}); err != nil {
+		return err
+	}
+	b.reasons[p] = reason
+	return nil
+}
+
+// AddASNRange adds the inclusive range first-last of bogon ASNs.
+func (b *BogonList) AddASNRange(first, last uint32, reason string) error {
+	if last < first {
+		return fmt.Errorf("invalid ASN range %d-%d", first, last)
+	}
+	b.asns = append(b.asns, ASNRange{First: first, Last: last, Reason: reason})
+	sort.Slice(b.asns, func(i, j int) bool { return b.asns[i].First < b.asns[j].First })
+	return nil
+}
+
+// LoadPrefixes adds one bogon prefix per line from rd, as published in the
+// Team Cymru bogon and fullbogon lists, all with the given reason. Blank
+// lines and lines starting with '#' are ignored.
+func (b *BogonList) LoadPrefixes(rd io.Reader, reason string) error {
+	sc := bufio.NewScanner(rd)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		p, err := netip.ParsePrefix(text)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := b.AddPrefix(p, reason); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return sc.Err()
+}
+
+// PrefixReason reports whether p lies within a bogon prefix and, if so,
+// which one and why.
+func (b *BogonList) PrefixReason(p netip.Prefix) (string, bool) {
+	e, ok := b.prefixes.Lookup(p)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("bogon prefix %s: %s", e.Prefix, b.reasons[e.Prefix]), true
+}
+
+// ASNReason reports whether asn is a bogon ASN and, if so, why.
+func (b *BogonList) ASNReason(asn uint32) (string, bool) {
+	for _, r := range b.asns {
+		if r.First > asn {
+			break
+		}
+		if asn <= r.Last {
+			return fmt.Sprintf("bogon ASN %d: %s", asn, r.Reason), true
+		}
+	}
+	return "", false
+}
+
+// Check reports whether the route's prefix is a bogon or its AS path
+// contains a bogon ASN, with the reason for the first hit.
+func (b *BogonList) Check(route Route) (string, bool) {
+	if reason, ok := b.PrefixReason(route.Prefix); ok {
+		return reason, true
+	}
+	if route.Attributes != nil {
+		for _, asn := range route.Attributes.AsPath {
+			if reason, ok := b.ASNReason(asn); ok {
+				return reason, true
+			}
+		}
+	}
+	return "", false
+}
+
+// BogonRoute is a route found to be a bogon, with the reason.
+type BogonRoute struct {
+	Route  Route
+	Reason string
+}
+
+// Bogons walks the entire RIB and returns every path whose prefix is a bogon
+// or whose AS path contains a bogon ASN, IPv4 before IPv6, in address order.
+func (r *Rib) Bogons(b *BogonList) []BogonRoute {
+	var found []BogonRoute
+	collect := func(prefix netip.Prefix, n *node) {
+		for _, rt := range sortedRoutes(n, prefix) {
+			if reason, ok := b.Check(rt); ok {
+				found = append(found, BogonRoute{Route: rt, Reason: reason})
+			}
+		}
+	}
+
+	r.v4mu.RLock()
+	r.walkIPv4(collect)
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(collect)
+	r.v6mu.RUnlock()
+
+	return found
+}
+
+// SetBogonFilter installs b as an insert-time reject filter. A bogon update
+// is rejected, withdraws any path previously stored for the same prefix and
+// PathID, and is recorded with its reason until the next accepted update for
+// that prefix and PathID. Passing nil removes the filter. Installing a filter
+// clears the recorded rejections; routes already in the RIB are not
+// re-evaluated.
+func (r *Rib) SetBogonFilter(b *BogonList) {
+	r.v4mu.Lock()
+	r.v6mu.Lock()
+	defer r.v4mu.Unlock()
+	defer r.v6mu.Unlock()
+	r.bogonFilter = b
+	r.v4Bogons = nil
+	r.v6Bogons = nil
+}
+
+// BogonRejections returns the updates most recently rejected by the bogon
+// filter, one per prefix and PathID, IPv4 before IPv6, in address order.
+func (r *Rib) BogonRejections() []BogonRoute {
+	var rejected []BogonRoute
+
+	r.v4mu.RLock()
+	for _, br := range r.v4Bogons {
+		rejected = append(rejected, br)
+	}
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	for _, br := range r.v6Bogons {
+		rejected = append(rejected, br)
+	}
+	r.v6mu.RUnlock()
+
+	sort.Slice(rejected, func(i, j int) bool {
+		a, b := rejected[i].Route, rejected[j].Route
+		if c := comparePrefixes(a.Prefix, b.Prefix); c != 0 {
+			return c < 0
+		}
+		return a.PathID < b.PathID
+	})
+	return rejected
+}
+
+// bogonRejected reports whether the bogon filter rejects the route, recording
+// the rejection, and forgets any earlier rejection of an accepted route. The
+// caller must hold the lock of the route's address family.
+func (r *Rib) bogonRejected(route Route) bool {
+	if r.bogonFilter == nil {
+		return false
+	}
+	rejects := &r.v4Bogons
+	if route.Prefix.Addr().Is6() {
+		rejects = &r.v6Bogons
+	}
+	key := PrefixWithID{Prefix: route.Prefix.Masked(), PathID: route.PathID}
+
+	reason, bogon := r.bogonFilter.Check(route)
+	if !bogon {
+		delete(*rejects, key)
+		return false
+	}
+	if *rejects == nil {
+		*rejects = make(map[PrefixWithID]BogonRoute)
+	}
+	// Copy the attributes, which the caller may reuse after the insert.
+	route.Attributes = copyAttributes(route.Attributes)
+	(*rejects)[key] = BogonRoute{Route: route, Reason: reason}
+	return true
+}
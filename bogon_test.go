@@ -0,0 +1,115 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestBogonList(t *testing.T) {
+	b := rib.DefaultBogons()
+
+	prefixes := []struct {
+		prefix string
+		bogon  bool
+	}{
+		{"10.0.0.0/8", true},
+		{"10.20.0.0/16", true},
+		{"100.64.0.0/10", true},
+		{"100.128.0.0/10", false},
+		{"192.0.2.0/24", true},
+		{"192.88.99.0/24", true},
+		{"8.8.8.0/24", false},
+		{"2001:db8:1::/48", true},
+		{"2001:4860::/32", false},
+		{"fc00::/8", true},
+	}
+	for _, tc := range prefixes {
+		if _, got := b.PrefixReason(netip.MustParsePrefix(tc.prefix)); got != tc.bogon {
+			t.Errorf("PrefixReason(%s) = %v, want %v", tc.prefix, got, tc.bogon)
+		}
+	}
+
+	asns := []struct {
+		asn   uint32
+		bogon bool
+	}{
+		{0, true}, {1, false}, {23456, true}, {64495, false}, {64496, true}, {65000, true},
+		{100000, true}, {131071, true}, {131072, false}, {4199999999, false}, {4200000000, true}, {4294967295, true},
+	}
+	for _, tc := range asns {
+		if _, got := b.ASNReason(tc.asn); got != tc.bogon {
+			t.Errorf("ASNReason(%d) = %v, want %v", tc.asn, got, tc.bogon)
+		}
+	}
+
+	if err := b.LoadPrefixes(strings.NewReader("# fullbogons\n\n41.0.0.0/8\n"), "unallocated"); err != nil {
+		t.Fatal(err)
+	}
+	if reason, ok := b.PrefixReason(netip.MustParsePrefix("41.1.0.0/16")); !ok || !strings.Contains(reason, "unallocated") {
+		t.Errorf("expected loaded prefix to be a bogon, got %q", reason)
+	}
+	if err := b.LoadPrefixes(strings.NewReader("not-a-prefix\n"), ""); err == nil {
+		t.Error("expected error for invalid line")
+	}
+}
+
+func TestBogonScanAndFilter(t *testing.T) {
+	clean := &rib.RouteAttributes{AsPath: []uint32{3356, 15169}}
+	private := &rib.RouteAttributes{AsPath: []uint32{3356, 65001, 15169}}
+
+	router := rib.GetNewRib()
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("8.8.8.0/24"), Attributes: clean})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("9.9.9.0/24"), Attributes: private})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("192.168.0.0/16"), Attributes: clean})
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32"), Attributes: clean})
+
+	found := router.Bogons(rib.DefaultBogons())
+	want := []string{"9.9.9.0/24", "192.168.0.0/16", "2001:db8::/32"}
+	if len(found) != len(want) {
+		t.Fatalf("expected %d bogons, got %v", len(want), found)
+	}
+	for i, w := range want {
+		if found[i].Route.Prefix != netip.MustParsePrefix(w) {
+			t.Errorf("bogon %d: got %s, want %s", i, found[i].Route.Prefix, w)
+		}
+	}
+	if !strings.Contains(found[0].Reason, "ASN 65001") {
+		t.Errorf("unexpected reason %q", found[0].Reason)
+	}
+
+	router.SetBogonFilter(rib.DefaultBogons())
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Attributes: clean})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("9.9.9.0/24"), Attributes: private}) // withdraws the stored path
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24"), Attributes: clean})
+	if router.LookupIPv4(netip.MustParsePrefix("10.1.0.0/16")) != nil || router.LookupIPv4(netip.MustParsePrefix("9.9.9.0/24")) != nil {
+		t.Error("expected bogon routes to be rejected")
+	}
+	if router.LookupIPv4(netip.MustParsePrefix("1.1.1.0/24")) == nil {
+		t.Error("expected clean route to be accepted")
+	}
+
+	rejected := router.BogonRejections()
+	if len(rejected) != 2 || rejected[0].Route.Prefix != netip.MustParsePrefix("9.9.9.0/24") || !strings.Contains(rejected[1].Reason, "RFC 1918") {
+		t.Fatalf("unexpected rejections %v", rejected)
+	}
+	// The record keeps its own copy of the rejected attributes.
+	private.AsPath[len(private.AsPath)-1] = 13335
+	if got := router.BogonRejections()[0].Route.Attributes.AsPath; got[len(got)-1] == 13335 {
+		t.Errorf("rejection changed with the caller's attributes: %v", got)
+	}
+
+	// An accepted re-announcement clears the recorded rejection.
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("9.9.9.0/24"), Attributes: clean})
+	if got := router.BogonRejections(); len(got) != 1 {
+		t.Errorf("expected 1 recorded rejection, got %v", got)
+	}
+
+	router.SetBogonFilter(nil)
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Attributes: clean})
+	if router.LookupIPv4(netip.MustParsePrefix("10.1.0.0/16")) == nil || len(router.BogonRejections()) != 0 {
+		t.Error("expected filter removal to accept bogons and clear rejections")
+	}
+}
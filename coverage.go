@@ -0,0 +1,130 @@
+package routing_table
+
+import (
+	"net/netip"
+)
+
+// Coverage is the amount of address space reachable through the RIB. Space
+// covered by several overlapping prefixes is counted once.
+type Coverage struct {
+	V4Addresses uint64
+	V4Slash24s  uint64
+	V6Slash48s  uint64
+}
+
+// Coverage walks both tries and returns the routed address space. A prefix
+// with a route covers its whole subtree, so the walk does not descend below it.
+func (r *Rib) Coverage() Coverage {
+	var c Coverage
+
+	r.v4mu.RLock()
+	for i := 0; i < 256; i++ {
+		c.V4Addresses += coveredSpace(r.ipv4Root[i], 8, 32)
+	}
+	r.v4mu.RUnlock()
+	c.V4Slash24s = c.V4Addresses >> 8
+
+	r.v6mu.RLock()
+	for i := 0; i < 32; i++ {
+		c.V6Slash48s += coveredSpace(r.ipv6Root[i], 8, 48)
+	}
+	r.v6mu.RUnlock()
+
+	return c
+}
+
+// coveredSpace returns the number of unit-length blocks (2^(unit-depth) per
+// routed node) covered by the subtrie rooted at n.
+func coveredSpace(n *node, depth, unit int) uint64 {
+	if n == nil {
+		return 0
+	}
+	if len(n.paths) > 0 {
+		return 1 << (unit - depth)
+	}
+	return coveredSpace(n.children[0], depth+1, unit) + coveredSpace(n.children[1], depth+1, unit)
+}
+
+// UnroutedGaps returns the parts of supernet not covered by any route, as the
+// smallest list of CIDR blocks in address order. supernet may be of either
+// family and any length. IPv6 space outside 2000::/3 can never be routed by
+// the RIB and is always reported as a gap.
+func (r *Rib) UnroutedGaps(supernet netip.Prefix) []netip.Prefix {
+	if !supernet.IsValid() {
+		return nil
+	}
+	supernet = supernet.Masked()
+
+	if supernet.Addr().Is4() {
+		r.v4mu.RLock()
+		defer r.v4mu.RUnlock()
+		return unroutedGaps(supernet, 24, func(b byte) *node { return r.ipv4Root[b] })
+	}
+
+	r.v6mu.RLock()
+	defer r.v6mu.RUnlock()
+	return unroutedGaps(supernet, 48, func(b byte) *node {
+		if b < 0x20 || b > 0x3F {
+			return nil
+		}
+		return r.ipv6Root[b-0x20]
+	})
+}
+
+// unroutedGaps finds the gaps in supernet given a lookup for the root array
+// entry of each first byte and the deepest prefix length the trie stores.
+// The caller must hold the lock of the supernet's family.
+func unroutedGaps(supernet netip.Prefix, maxBits int, root func(byte) *node) []netip.Prefix {
+	var gaps []netip.Prefix
+
+	// Shorter than the root array: visit every /8 slot inside the supernet.
+	if supernet.Bits() < 8 {
+		first := supernet.Addr().AsSlice()[0]
+		count := 1 << (8 - supernet.Bits())
+		for i := 0; i < count; i++ {
+			addr := supernet.Addr().AsSlice()
+			addr[0] = first + byte(i)
+			slot, _ := netip.AddrFromSlice(addr)
+			gapsUnder(root(addr[0]), netip.PrefixFrom(slot, 8), maxBits, &gaps)
+		}
+		// Whole empty /8s are reported individually above; merge them back.
+		return AggregatePrefixes(gaps)
+	}
+
+	// Walk down to the supernet. A route on the way covers all of it.
+	addr := supernet.Addr().AsSlice()
+	n := root(addr[0])
+	for depth := 8; n != nil; depth++ {
+		if len(n.paths) > 0 {
+			return nil
+		}
+		if depth == supernet.Bits() || depth == maxBits {
+			break
+		}
+		n = n.children[addrBit(addr, depth)]
+	}
+	if supernet.Bits() > maxBits {
+		// Longer than anything the trie stores and not covered above.
+		return []netip.Prefix{supernet}
+	}
+	gapsUnder(n, supernet, maxBits, &gaps)
+	return gaps
+}
+
+// gapsUnder appends the unrouted parts of p, whose trie node is n, in address order.
+func gapsUnder(n *node, p netip.Prefix, maxBits int, gaps *[]netip.Prefix) {
+	if n == nil {
+		*gaps = append(*gaps, p)
+		return
+	}
+	if len(n.paths) > 0 {
+		return
+	}
+	if p.Bits() >= maxBits {
+		*gaps = append(*gaps, p)
+		return
+	}
+	lo := netip.PrefixFrom(p.Addr(), p.Bits()+1)
+	gapsUnder(n.children[0], lo, maxBits, gaps)
+	gapsUnder(n.children[1], siblingPrefix(lo), maxBits, gaps)
+}
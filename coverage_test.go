@@ -0,0 +1,70 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestCoverage(t *testing.T) {
+	router := rib.GetNewRib()
+	for _, p := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.1.0/24", "192.168.0.0/23", "192.168.1.0/24"} {
+		router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix(p)})
+	}
+	for _, p := range []string{"2001:db8::/32", "2001:db8:1::/48", "2a00::/47"} {
+		router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix(p)})
+	}
+
+	c := router.Coverage()
+	if want := uint64(1<<24 + 512); c.V4Addresses != want {
+		t.Errorf("expected %d v4 addresses, got %d", want, c.V4Addresses)
+	}
+	if want := uint64(1<<16 + 2); c.V4Slash24s != want {
+		t.Errorf("expected %d /24s, got %d", want, c.V4Slash24s)
+	}
+	if want := uint64(1<<16 + 2); c.V6Slash48s != want {
+		t.Errorf("expected %d /48s, got %d", want, c.V6Slash48s)
+	}
+}
+
+func TestUnroutedGaps(t *testing.T) {
+	router := rib.GetNewRib()
+	for _, p := range []string{"10.0.0.0/9", "10.192.0.0/10", "11.0.0.0/8"} {
+		router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix(p)})
+	}
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/33")})
+
+	tests := []struct {
+		supernet string
+		want     []string
+	}{
+		{"10.0.0.0/8", []string{"10.128.0.0/10"}},
+		{"10.0.0.0/7", []string{"10.128.0.0/10"}},
+		{"10.1.2.0/24", nil},   // covered by 10.0.0.0/9
+		{"10.1.2.128/25", nil}, // longer than the trie, still covered
+		{"10.128.0.0/25", []string{"10.128.0.0/25"}},
+		{"12.0.0.0/8", []string{"12.0.0.0/8"}},
+		{"8.0.0.0/5", []string{"8.0.0.0/7", "10.128.0.0/10", "12.0.0.0/6"}},
+		{"2001:db8::/32", []string{"2001:db8:8000::/33"}},
+		{"2001:db8::/31", []string{"2001:db8:8000::/33", "2001:db9::/32"}},
+		{"fc00::/7", []string{"fc00::/7"}},
+		{"0.0.0.0/5", []string{"0.0.0.0/5"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.supernet, func(t *testing.T) {
+			got := router.UnroutedGaps(netip.MustParsePrefix(tc.supernet))
+			var want []netip.Prefix
+			for _, p := range tc.want {
+				want = append(want, netip.MustParsePrefix(p))
+			}
+			if len(got) == 0 && len(want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
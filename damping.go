@@ -0,0 +1,372 @@
+package routing_table
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// DampingConfig holds route flap damping parameters (RFC 2439). Penalties are
+// tracked per prefix and PathID, decay exponentially with HalfLife, and a
+// path is suppressed once its penalty reaches SuppressThreshold until it
+// decays below ReuseThreshold. The penalty is capped so that no path stays
+// suppressed for longer than MaxSuppressTime without further flaps.
+type DampingConfig struct {
+	HalfLife          time.Duration
+	ReuseThreshold    float64
+	SuppressThreshold float64
+	MaxSuppressTime   time.Duration
+
+	// Penalties added for a withdrawal, for a re-advertisement after a
+	// withdrawal, and for an update that changes a path's attributes.
+	WithdrawPenalty        float64
+	ReadvertisePenalty     float64
+	AttributeChangePenalty float64
+
+	// Clock returns the current time. It defaults to time.Now and can be
+	// replaced to make decay deterministic.
+	Clock func() time.Time
+}
+
+// DefaultDampingConfig returns the parameters recommended by RFC 7196.
+func DefaultDampingConfig() DampingConfig {
+	return DampingConfig{
+		HalfLife:               15 * time.Minute,
+		ReuseThreshold:         750,
+		SuppressThreshold:      6000,
+		MaxSuppressTime:        60 * time.Minute,
+		WithdrawPenalty:        1000,
+		ReadvertisePenalty:     0,
+		AttributeChangePenalty: 500,
+	}
+}
+
+func (c *DampingConfig) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock()
+	}
+	return time.Now()
+}
+
+// validate checks that the parameters give a finite maximum penalty and a
+// reuse threshold below the suppress threshold.
+func (c *DampingConfig) validate() error {
+	switch {
+	case c.HalfLife <= 0:
+		return fmt.Errorf("damping: half-life must be positive")
+	case c.MaxSuppressTime <= 0:
+		return fmt.Errorf("damping: max suppress time must be positive")
+	case !(c.ReuseThreshold > 0 && c.ReuseThreshold < c.SuppressThreshold):
+		return fmt.Errorf("damping: reuse threshold %v must be above zero and below suppress threshold %v", c.ReuseThreshold, c.SuppressThreshold)
+	}
+	return nil
+}
+
+// maxPenalty is the penalty that takes exactly MaxSuppressTime to decay to
+// the reuse threshold.
+func (c *DampingConfig) maxPenalty() float64 {
+	return c.ReuseThreshold * math.Exp2(float64(c.MaxSuppressTime)/float64(c.HalfLife))
+}
+
+// dampState is the flap history of one prefix and PathID. It outlives the
+// path itself so that a withdraw/re-advertise cycle keeps accumulating.
+type dampState struct {
+	penalty    float64
+	updated    time.Time
+	flaps      int
+	suppressed bool
+	since      time.Time
+	withdrawn  bool
+}
+
+// decay brings the penalty forward to now.
+func (d *dampState) decay(cfg *DampingConfig, now time.Time) {
+	if elapsed := now.Sub(d.updated); elapsed > 0 {
+		d.penalty *= math.Exp2(-float64(elapsed) / float64(cfg.HalfLife))
+	}
+	d.updated = now
+}
+
+// DampingInfo describes the flap history of a path.
+type DampingInfo struct {
+	Penalty    float64
+	Flaps      int
+	Suppressed bool
+	// SuppressedSince is when the path was last suppressed.
+	SuppressedSince time.Time
+	// ReuseAt is when the penalty will have decayed below the reuse
+	// threshold, assuming no further flaps.
+	ReuseAt time.Time
+	// Withdrawn is set when the path is currently not in the RIB.
+	Withdrawn bool
+	// Reason explains the suppression in words; it is empty when the path is
+	// not suppressed.
+	Reason string
+}
+
+// DampedRoute is a suppressed path with its damping information.
+type DampedRoute struct {
+	Prefix netip.Prefix
+	PathID uint32
+	Info   DampingInfo
+}
+
+// SetDamping enables flap damping with a copy of cfg, or disables it when cfg
+// is nil. Changing the configuration discards all flap history and releases
+// every suppressed path. It returns an error, leaving the configuration
+// unchanged, unless HalfLife and MaxSuppressTime are positive and
+// ReuseThreshold is above zero and below SuppressThreshold.
+//
+// Suppressed paths are only released by ReuseDamped, which the owner of the
+// RIB must call periodically; until then they stay out of best path
+// selection and searches even once their penalty has decayed.
+func (r *Rib) SetDamping(cfg *DampingConfig) error {
+	if cfg != nil {
+		if err := cfg.validate(); err != nil {
+			return err
+		}
+		copied := *cfg
+		cfg = &copied
+	}
+	r.v4mu.Lock()
+	r.v6mu.Lock()
+	defer r.v4mu.Unlock()
+	defer r.v6mu.Unlock()
+
+	for key, d := range r.v4Damp {
+		r.setSuppressed(key, d, false)
+	}
+	for key, d := range r.v6Damp {
+		r.setSuppressed(key, d, false)
+	}
+	r.damping = cfg
+	r.v4Damp = nil
+	r.v6Damp = nil
+	return nil
+}
+
+// Damping returns the flap history of a prefix and PathID, and whether it
+// has one.
+func (r *Rib) Damping(prefix netip.Prefix, pathID uint32) (DampingInfo, bool) {
+	mu := r.v4mu
+	if prefix.Addr().Is6() {
+		mu = r.v6mu
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if r.damping == nil {
+		return DampingInfo{}, false
+	}
+	d := r.dampStates(prefix)[PrefixWithID{prefix.Masked(), pathID}]
+	if d == nil {
+		return DampingInfo{}, false
+	}
+	return r.dampingInfo(d), true
+}
+
+// SuppressedPaths returns every suppressed path, IPv4 before IPv6 in address
+// order, with the reason for its suppression.
+func (r *Rib) SuppressedPaths() []DampedRoute {
+	var damped []DampedRoute
+
+	r.v4mu.RLock()
+	r.v6mu.RLock()
+	for _, states := range []map[PrefixWithID]*dampState{r.v4Damp, r.v6Damp} {
+		for key, d := range states {
+			if d.suppressed {
+				damped = append(damped, DampedRoute{Prefix: key.Prefix, PathID: key.PathID, Info: r.dampingInfo(d)})
+			}
+		}
+	}
+	r.v6mu.RUnlock()
+	r.v4mu.RUnlock()
+
+	sort.Slice(damped, func(i, j int) bool {
+		if c := comparePrefixes(damped[i].Prefix, damped[j].Prefix); c != 0 {
+			return c < 0
+		}
+		return damped[i].PathID < damped[j].PathID
+	})
+	return damped
+}
+
+// ReuseDamped decays every penalty to the current time, releases suppressed
+// paths whose penalty has fallen below the reuse threshold, and forgets
+// histories that have decayed below half of it. It returns the released paths
+// still in the RIB. Penalties only decay when evaluated, so ReuseDamped must
+// be called periodically, for example once every few seconds, for suppressed
+// paths to come back.
+func (r *Rib) ReuseDamped() []PrefixWithID {
+	var released []PrefixWithID
+
+	r.v4mu.Lock()
+	released = r.reuseDamped(r.v4Damp, released)
+	r.v4mu.Unlock()
+
+	r.v6mu.Lock()
+	released = r.reuseDamped(r.v6Damp, released)
+	r.v6mu.Unlock()
+
+	return released
+}
+
+func (r *Rib) reuseDamped(states map[PrefixWithID]*dampState, released []PrefixWithID) []PrefixWithID {
+	if r.damping == nil {
+		return released
+	}
+	now := r.damping.now()
+	var keys []PrefixWithID
+	for key, d := range states {
+		d.decay(r.damping, now)
+		if d.suppressed && d.penalty < r.damping.ReuseThreshold {
+			r.setSuppressed(key, d, false)
+			if !d.withdrawn {
+				keys = append(keys, key)
+			}
+		}
+		if d.penalty < r.damping.ReuseThreshold/2 {
+			delete(states, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if c := comparePrefixes(keys[i].Prefix, keys[j].Prefix); c != 0 {
+			return c < 0
+		}
+		return keys[i].PathID < keys[j].PathID
+	})
+	return append(released, keys...)
+}
+
+// dampUpdate records an advertisement of route, whose previous attributes
+// were oldAttr (nil for a new path), and suppresses it if needed. The caller
+// must hold the lock of the route's address family.
+func (r *Rib) dampUpdate(route Route, oldAttr, newAttr *RouteAttributes) {
+	key := PrefixWithID{route.Prefix.Masked(), route.PathID}
+	d := r.dampStates(key.Prefix)[key]
+
+	var penalty float64
+	switch {
+	case oldAttr == nil && d != nil && d.withdrawn:
+		penalty = r.damping.ReadvertisePenalty
+	case oldAttr != nil && oldAttr != newAttr:
+		penalty = r.damping.AttributeChangePenalty
+	}
+	if d != nil {
+		d.withdrawn = false
+	}
+	r.dampPenalize(key, d, penalty)
+}
+
+// dampWithdraw records a withdrawal of a prefix and PathID. The caller must
+// hold the lock of the prefix's address family.
+func (r *Rib) dampWithdraw(prefix netip.Prefix, pathID uint32) {
+	key := PrefixWithID{prefix.Masked(), pathID}
+	d := r.dampPenalize(key, r.dampStates(key.Prefix)[key], r.damping.WithdrawPenalty)
+	if d != nil {
+		d.withdrawn = true
+	}
+}
+
+// dampPenalize adds penalty to the history d of key, creating it if needed,
+// and updates suppression. It returns the history, which is nil when there
+// was none and penalty is zero.
+func (r *Rib) dampPenalize(key PrefixWithID, d *dampState, penalty float64) *dampState {
+	if d == nil && penalty == 0 {
+		return nil
+	}
+	cfg := r.damping
+	now := cfg.now()
+	if d == nil {
+		d = &dampState{updated: now}
+		states := &r.v4Damp
+		if key.Prefix.Addr().Is6() {
+			states = &r.v6Damp
+		}
+		if *states == nil {
+			*states = make(map[PrefixWithID]*dampState)
+		}
+		(*states)[key] = d
+	}
+
+	d.decay(cfg, now)
+	if penalty > 0 {
+		d.penalty = min(d.penalty+penalty, cfg.maxPenalty())
+		d.flaps++
+	}
+	switch {
+	case !d.suppressed && d.penalty >= cfg.SuppressThreshold:
+		d.since = now
+		r.setSuppressed(key, d, true)
+	case d.suppressed && d.penalty < cfg.ReuseThreshold:
+		r.setSuppressed(key, d, false)
+	case d.suppressed:
+		// Re-apply to a path that was re-advertised while suppressed.
+		r.setSuppressed(key, d, true)
+	}
+	return d
+}
+
+// setSuppressed records suppression in d and mirrors it onto the path in the
+// trie, if present.
+func (r *Rib) setSuppressed(key PrefixWithID, d *dampState, suppressed bool) {
+	d.suppressed = suppressed
+	n := r.nodeFor(key.Prefix)
+	if n == nil || n.paths[key.PathID] == nil {
+		return
+	}
+	if suppressed {
+		n.pathMeta(key.PathID, true).suppressed = true
+	} else if m := n.pathMeta(key.PathID, false); m != nil {
+		m.suppressed = false
+		n.tidyMeta(key.PathID)
+	}
+}
+
+func (r *Rib) dampStates(prefix netip.Prefix) map[PrefixWithID]*dampState {
+	if prefix.Addr().Is6() {
+		return r.v6Damp
+	}
+	return r.v4Damp
+}
+
+// dampingInfo reports d as of now without modifying it.
+func (r *Rib) dampingInfo(d *dampState) DampingInfo {
+	cfg := r.damping
+	now := cfg.now()
+	penalty := d.penalty
+	if elapsed := now.Sub(d.updated); elapsed > 0 {
+		penalty *= math.Exp2(-float64(elapsed) / float64(cfg.HalfLife))
+	}
+
+	info := DampingInfo{
+		Penalty:    penalty,
+		Flaps:      d.flaps,
+		Suppressed: d.suppressed,
+		Withdrawn:  d.withdrawn,
+	}
+	if penalty > cfg.ReuseThreshold {
+		info.ReuseAt = now.Add(time.Duration(math.Log2(penalty/cfg.ReuseThreshold) * float64(cfg.HalfLife)))
+	} else {
+		info.ReuseAt = now
+	}
+	if d.suppressed {
+		info.SuppressedSince = d.since
+		info.Reason = fmt.Sprintf("penalty %.0f reached suppress threshold %.0f after %d flaps; reusable below %.0f at %s",
+			penalty, cfg.SuppressThreshold, d.flaps, cfg.ReuseThreshold, info.ReuseAt.Format(time.RFC3339))
+	}
+	return info
+}
+
+// cloneDampStates deep copies a family's flap histories.
+func cloneDampStates(states map[PrefixWithID]*dampState) map[PrefixWithID]*dampState {
+	c := maps.Clone(states)
+	for k, d := range c {
+		copied := *d
+		c[k] = &copied
+	}
+	return c
+}
@@ -0,0 +1,139 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestFlapDamping(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := rib.DefaultDampingConfig()
+	cfg.Clock = func() time.Time { return now }
+
+	router := rib.GetNewRib()
+	if err := router.SetDamping(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	stable := netip.MustParsePrefix("10.0.0.0/8")
+	flappy := netip.MustParsePrefix("10.1.0.0/16")
+	addr := netip.MustParseAddr("10.1.2.3")
+	attr := &rib.RouteAttributes{AsPath: []uint32{64500}}
+	router.InsertIPv4(rib.Route{Prefix: stable, Attributes: attr})
+
+	// Six withdrawals in quick succession take the penalty past 6000.
+	for i := 0; i < 6; i++ {
+		router.InsertIPv4(rib.Route{Prefix: flappy, Attributes: attr})
+		router.DeleteIPv4(flappy, 0)
+		now = now.Add(time.Second)
+	}
+	router.InsertIPv4(rib.Route{Prefix: flappy, Attributes: attr})
+	info, ok := router.Damping(flappy, 0)
+	if !ok || info.Suppressed {
+		t.Fatalf("expected not suppressed yet at penalty %.0f", info.Penalty)
+	}
+	router.DeleteIPv4(flappy, 0)
+	router.InsertIPv4(rib.Route{Prefix: flappy, Attributes: attr})
+
+	info, _ = router.Damping(flappy, 0)
+	if !info.Suppressed || info.Flaps != 7 || !strings.Contains(info.Reason, "suppress threshold 6000") {
+		t.Fatalf("expected suppression after 7 flaps, got %+v", info)
+	}
+
+	// Hidden from best path selection: lookups fall back to the covering route.
+	if got := router.SearchIPv4(addr); got == nil || got.Prefix != stable {
+		t.Errorf("expected %s while suppressed, got %v", stable, got)
+	}
+	if got := router.LookupIPv4(flappy); got != nil {
+		t.Errorf("expected no best path for suppressed prefix, got %v", got)
+	}
+	if got := router.AllPathsIPv4(flappy); len(got) != 1 {
+		t.Errorf("expected suppressed path still stored, got %v", got)
+	}
+	if got := router.SuppressedPaths(); len(got) != 1 || got[0].Prefix != flappy {
+		t.Errorf("unexpected suppressed paths %v", got)
+	}
+
+	// Not yet reusable after one half-life.
+	now = now.Add(15 * time.Minute)
+	if got := router.ReuseDamped(); len(got) != 0 {
+		t.Errorf("released too early: %v", got)
+	}
+
+	// The penalty is capped, so the path is reused within the max suppress time.
+	now = now.Add(45 * time.Minute)
+	released := router.ReuseDamped()
+	if len(released) != 1 || released[0].Prefix != flappy {
+		t.Fatalf("expected %s released, got %v", flappy, released)
+	}
+	if got := router.SearchIPv4(addr); got == nil || got.Prefix != flappy {
+		t.Errorf("expected %s after reuse, got %v", flappy, got)
+	}
+
+	// Histories decayed below half the reuse threshold are forgotten.
+	now = now.Add(time.Hour)
+	router.ReuseDamped()
+	if _, ok := router.Damping(flappy, 0); ok {
+		t.Error("expected flap history to be forgotten")
+	}
+}
+
+func TestFlapDampingAttributeChange(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := rib.DefaultDampingConfig()
+	cfg.Clock = func() time.Time { return now }
+
+	router := rib.GetNewRib()
+	if err := router.SetDamping(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	prefix := netip.MustParsePrefix("2001:db8::/32")
+
+	for i := 0; i < 14; i++ {
+		router.InsertIPv6(rib.Route{Prefix: prefix, Attributes: &rib.RouteAttributes{AsPath: []uint32{64500, uint32(i % 2)}}, PathID: 7})
+	}
+	// Same attributes again: no penalty.
+	router.InsertIPv6(rib.Route{Prefix: prefix, Attributes: &rib.RouteAttributes{AsPath: []uint32{64500, 1}}, PathID: 7})
+
+	info, ok := router.Damping(prefix, 7)
+	if !ok || info.Flaps != 13 || !info.Suppressed {
+		t.Errorf("expected 13 attribute-change flaps and suppression, got %+v", info)
+	}
+	if router.SearchIPv6(netip.MustParseAddr("2001:db8::1")) != nil {
+		t.Error("expected suppressed IPv6 path to be hidden")
+	}
+
+	router.SetDamping(nil)
+	if router.SearchIPv6(netip.MustParseAddr("2001:db8::1")) == nil {
+		t.Error("expected path released when damping is disabled")
+	}
+}
+
+func TestDampingConfigValidation(t *testing.T) {
+	bad := []func(*rib.DampingConfig){
+		func(c *rib.DampingConfig) { c.HalfLife = 0 },
+		func(c *rib.DampingConfig) { c.MaxSuppressTime = 0 },
+		func(c *rib.DampingConfig) { c.ReuseThreshold = 0 },
+		func(c *rib.DampingConfig) { c.ReuseThreshold = c.SuppressThreshold },
+	}
+	router := rib.GetNewRib()
+	prefix := netip.MustParsePrefix("10.0.0.0/8")
+	for i, mutate := range bad {
+		cfg := rib.DefaultDampingConfig()
+		mutate(&cfg)
+		if err := router.SetDamping(&cfg); err == nil {
+			t.Errorf("config %d: expected an error", i)
+		}
+	}
+
+	// Rejected configurations leave damping off.
+	router.InsertIPv4(rib.Route{Prefix: prefix})
+	router.DeleteIPv4(prefix, 0)
+	if _, ok := router.Damping(prefix, 0); ok {
+		t.Error("expected damping to stay disabled")
+	}
+}
@@ -0,0 +1,271 @@
+package routing_table
+
+import (
+	"fmt"
+	"iter"
+	"net/netip"
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// DiffKind classifies a RouteDiff.
+type DiffKind uint8
+
+const (
+	// DiffRemoved is a path present only in the old RIB.
+	DiffRemoved DiffKind = iota
+	// DiffAdded is a path present only in the new RIB.
+	DiffAdded
+	// DiffChanged is a path present in both with different attributes.
+	DiffChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffRemoved:
+		return "removed"
+	case DiffAdded:
+		return "added"
+	case DiffChanged:
+		return "changed"
+	}
+	return fmt.Sprintf("DiffKind(%d)", uint8(k))
+}
+
+// RouteDiff is a single path-level difference between two RIBs. Old is nil
+// for added paths and New is nil for removed paths.
+type RouteDiff struct {
+	Kind   DiffKind
+	Prefix netip.Prefix
+	PathID uint32
+	Old    *RouteAttributes
+	New    *RouteAttributes
+}
+
+// DiffSummary counts the differences between two RIBs. A prefix is added or
+// removed when it has paths on only one side, and changed when it has paths
+// on both sides that differ in any PathID or attribute.
+type DiffSummary struct {
+	AddedPrefixes   int
+	RemovedPrefixes int
+	ChangedPrefixes int
+	AddedPaths      int
+	RemovedPaths    int
+	ChangedPaths    int
+}
+
+func (s DiffSummary) String() string {
+	return fmt.Sprintf("prefixes: +%d -%d ~%d, paths: +%d -%d ~%d",
+		s.AddedPrefixes, s.RemovedPrefixes, s.ChangedPrefixes,
+		s.AddedPaths, s.RemovedPaths, s.ChangedPaths)
+}
+
+// Diff returns an iterator over the path-level differences from r (old) to
+// other (new), IPv4 before IPv6, in address order and by PathID within a
+// prefix. Both tries are walked in lockstep, so no intermediate dump of
+// either RIB is built.
+//
+// Read locks on both RIBs are held for one address family at a time while
+// the iterator runs; the loop body must not modify either RIB. Take a Clone
+// first to diff against a RIB that is still being updated.
+func (r *Rib) Diff(other *Rib) iter.Seq[RouteDiff] {
+	return func(yield func(RouteDiff) bool) {
+		r.diffNodes(other, func(prefix netip.Prefix, a, b *node) bool {
+			return diffPaths(prefix, a, b, yield)
+		})
+	}
+}
+
+// DiffSummary walks both RIBs like Diff and returns only the counts.
+func (r *Rib) DiffSummary(other *Rib) DiffSummary {
+	var s DiffSummary
+	r.diffNodes(other, func(prefix netip.Prefix, a, b *node) bool {
+		var before, after, changed int
+		diffPaths(prefix, a, b, func(d RouteDiff) bool {
+			switch d.Kind {
+			case DiffRemoved:
+				s.RemovedPaths++
+				before++
+			case DiffAdded:
+				s.AddedPaths++
+				after++
+			case DiffChanged:
+				s.ChangedPaths++
+				changed++
+			}
+			return true
+		})
+		switch {
+		case before+after+changed == 0:
+		case a == nil || len(a.paths) == 0:
+			s.AddedPrefixes++
+		case b == nil || len(b.paths) == 0:
+			s.RemovedPrefixes++
+		default:
+			s.ChangedPrefixes++
+		}
+		return true
+	})
+	return s
+}
+
+// diffNodes walks both RIBs in lockstep and calls fn for every prefix that
+// holds paths on at least one side. fn returns false to stop the walk.
+func (r *Rib) diffNodes(other *Rib, fn func(prefix netip.Prefix, a, b *node) bool) {
+	if r == other {
+		return
+	}
+	if r.diffIPv4(other, fn) {
+		r.diffIPv6(other, fn)
+	}
+}
+
+func (r *Rib) diffIPv4(other *Rib, fn func(netip.Prefix, *node, *node) bool) bool {
+	first, second := lockOrder(r.v4mu, other.v4mu)
+	first.RLock()
+	defer first.RUnlock()
+	if second != nil {
+		second.RLock()
+		defer second.RUnlock()
+	}
+	return r.diffIPv4Unlocked(other, fn)
+}
+
+// lockOrder returns two RIBs' locks for the same address family in a fixed
+// order, so that operations locking both, such as a.Diff(b) and b.Diff(a)
+// running at the same time, cannot deadlock once a writer queues on either.
+// second is nil when both RIBs share the lock, as a copied Rib value does, so
+// that it is only taken once.
+func lockOrder(a, b *sync.RWMutex) (first, second *sync.RWMutex) {
+	if a == b {
+		return a, nil
+	}
+	if uintptr(unsafe.Pointer(b)) < uintptr(unsafe.Pointer(a)) {
+		return b, a
+	}
+	return a, b
+}
+
+// diffIPv4Unlocked is diffIPv4 for callers holding both RIBs' v4mu.
+func (r *Rib) diffIPv4Unlocked(other *Rib, fn func(netip.Prefix, *node, *node) bool) bool {
+	for i := 0; i < 256; i++ {
+		var addr [16]byte
+		addr[0] = byte(i)
+		if !diffWalk(r.ipv4Root[i], other.ipv4Root[i], addr, 8, 24, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rib) diffIPv6(other *Rib, fn func(netip.Prefix, *node, *node) bool) bool {
+	first, second := lockOrder(r.v6mu, other.v6mu)
+	first.RLock()
+	defer first.RUnlock()
+	if second != nil {
+		second.RLock()
+		defer second.RUnlock()
+	}
+	return r.diffIPv6Unlocked(other, fn)
+}
+
+// diffIPv6Unlocked is diffIPv6 for callers holding both RIBs' v6mu.
+func (r *Rib) diffIPv6Unlocked(other *Rib, fn func(netip.Prefix, *node, *node) bool) bool {
+	for i := 0; i < 32; i++ {
+		var addr [16]byte
+		addr[0] = byte(i + 0x20)
+		if !diffWalk(r.ipv6Root[i], other.ipv6Root[i], addr, 8, 48, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// diffWalk recursively walks two subtries at the same position. maxDepth
+// selects the family: 24 for IPv4, whose address lives in addr[:4], or 48 for IPv6.
+func diffWalk(a, b *node, addr [16]byte, depth, maxDepth int, fn func(netip.Prefix, *node, *node) bool) bool {
+	if a == nil && b == nil {
+		return true
+	}
+	if (a != nil && len(a.paths) > 0) || (b != nil && len(b.paths) > 0) {
+		var ip netip.Addr
+		if maxDepth == 24 {
+			ip = netip.AddrFrom4([4]byte(addr[:4]))
+		} else {
+			ip = netip.AddrFrom16(addr)
+		}
+		if !fn(netip.PrefixFrom(ip, depth), a, b) {
+			return false
+		}
+	}
+
+	if depth >= maxDepth {
+		return true
+	}
+
+	for bit := 0; bit < 2; bit++ {
+		var ca, cb *node
+		if a != nil {
+			ca = a.children[bit]
+		}
+		if b != nil {
+			cb = b.children[bit]
+		}
+		if ca == nil && cb == nil {
+			continue
+		}
+		nextAddr := addr
+		if bit == 1 {
+			nextAddr[depth/8] |= 1 << uint(7-depth%8)
+		}
+		if !diffWalk(ca, cb, nextAddr, depth+1, maxDepth, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// diffPaths yields the differences between the paths of a and b, either of
+// which may be nil, ordered by PathID.
+func diffPaths(prefix netip.Prefix, a, b *node, yield func(RouteDiff) bool) bool {
+	var ids []uint32
+	if a != nil {
+		for id := range a.paths {
+			ids = append(ids, id)
+		}
+	}
+	if b != nil {
+		for id := range b.paths {
+			if a == nil || a.paths[id] == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		var oldAttr, newAttr *RouteAttributes
+		if a != nil {
+			oldAttr = a.paths[id]
+		}
+		if b != nil {
+			newAttr = b.paths[id]
+		}
+		d := RouteDiff{Prefix: prefix, PathID: id, Old: oldAttr, New: newAttr}
+		switch {
+		case newAttr == nil:
+			d.Kind = DiffRemoved
+		case oldAttr == nil:
+			d.Kind = DiffAdded
+		case oldAttr.hash == newAttr.hash && equalAttributes(oldAttr, newAttr):
+			continue
+		default:
+			d.Kind = DiffChanged
+		}
+		if !yield(d) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestDiff(t *testing.T) {
+	before := rib.GetNewRib()
+	a1 := &rib.RouteAttributes{AsPath: []uint32{1, 2}}
+	a2 := &rib.RouteAttributes{AsPath: []uint32{1, 3}}
+
+	before.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Attributes: a1})
+	before.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Attributes: a1})
+	before.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Attributes: a1, PathID: 1})
+	before.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32"), Attributes: a1})
+
+	after := before.Clone()
+	if after.V4Count() != 2 || after.V6Count() != 1 || after.V4PathCount() != 3 {
+		t.Fatalf("clone has %d/%d prefixes and %d v4 paths", after.V4Count(), after.V6Count(), after.V4PathCount())
+	}
+	if s := before.DiffSummary(&after); s != (rib.DiffSummary{}) {
+		t.Fatalf("expected no differences after Clone, got %s", s)
+	}
+
+	after.DeleteIPv4(netip.MustParsePrefix("10.0.0.0/8"), 0)                                             // prefix removed
+	after.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Attributes: a2, PathID: 1}) // path changed
+	after.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Attributes: a1, PathID: 2}) // path added
+	after.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.1.1.0/24"), Attributes: a1})            // prefix added
+	after.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32"), Attributes: a1})          // unchanged
+
+	var got []rib.RouteDiff
+	for d := range before.Diff(&after) {
+		got = append(got, d)
+	}
+	want := []struct {
+		kind   rib.DiffKind
+		prefix string
+		pathID uint32
+	}{
+		{rib.DiffRemoved, "10.0.0.0/8", 0},
+		{rib.DiffChanged, "10.1.0.0/16", 1},
+		{rib.DiffAdded, "10.1.0.0/16", 2},
+		{rib.DiffAdded, "10.1.1.0/24", 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d differences, got %v", len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Kind != w.kind || got[i].Prefix != netip.MustParsePrefix(w.prefix) || got[i].PathID != w.pathID {
+			t.Errorf("diff %d: got %s %s path %d, want %s %s path %d",
+				i, got[i].Kind, got[i].Prefix, got[i].PathID, w.kind, w.prefix, w.pathID)
+		}
+	}
+	if got[1].Old.AsPath[1] != 2 || got[1].New.AsPath[1] != 3 {
+		t.Errorf("changed path has wrong attributes: %v -> %v", got[1].Old.AsPath, got[1].New.AsPath)
+	}
+
+	want2 := rib.DiffSummary{AddedPrefixes: 1, RemovedPrefixes: 1, ChangedPrefixes: 1, AddedPaths: 2, RemovedPaths: 1, ChangedPaths: 1}
+	if s := before.DiffSummary(&after); s != want2 {
+		t.Errorf("got summary %s, want %s", s, want2)
+	}
+
+	// Stopping early must not leave any lock held.
+	for range before.Diff(&after) {
+		break
+	}
+	after.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("11.0.0.0/8")})
+	before.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("11.0.0.0/8")})
+}
+
+func TestDiffCopiedRib(t *testing.T) {
+	a := rib.GetNewRib()
+	a.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24")})
+
+	// A copied Rib value shares a's locks and tries; its lock is taken once.
+	b := a
+	if got := a.DiffSummary(&b); got != (rib.DiffSummary{}) {
+		t.Errorf("expected no differences with a copy, got %v", got)
+	}
+}
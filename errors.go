@@ -0,0 +1,82 @@
+package routing_table
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// Reasons an insert or delete is refused. Errors returned by the insert and
+// delete methods are *RouteError values wrapping one of these, so callers can
+// test for them with errors.Is.
+var (
+	// ErrBadMask means the prefix length is outside /8–/24 for IPv4 or
+	// /8–/48 for IPv6.
+	ErrBadMask = errors.New("mask outside allowed range")
+	// ErrOutsideGlobalUnicast means an IPv6 prefix is not within 2000::/3.
+	ErrOutsideGlobalUnicast = errors.New("not within 2000::/3")
+	// ErrWrongFamily means an IPv6 prefix was passed to an IPv4 method, or
+	// the other way round.
+	ErrWrongFamily = errors.New("wrong address family")
+	// ErrBogon means the bogon filter rejected the route.
+	ErrBogon = errors.New("bogon")
+	// ErrFiltered means the import filter rejected the route.
+	ErrFiltered = errors.New("rejected by import filter")
+	// ErrPolicyRejected means the import policy rejected the route.
+	ErrPolicyRejected = errors.New("rejected by import policy")
+	// ErrLimitExceeded means a prefix or path limit rejected the insert.
+	ErrLimitExceeded = errors.New("limit exceeded")
+	// ErrNotFound means a delete named a path that is not in the RIB.
+	ErrNotFound = errors.New("path not found")
+	// ErrUnknownPeer means a PeerRib operation named a peer that was not added.
+	ErrUnknownPeer = errors.New("unknown peer")
+)
+
+// RouteError records a refused insert or delete and the reason for it.
+type RouteError struct {
+	Op     string // "insert" or "delete"
+	Prefix netip.Prefix
+	PathID uint32
+	Err    error
+}
+
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("%s %s path %d: %v", e.Op, e.Prefix, e.PathID, e.Err)
+}
+
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+func insertError(route Route, err error) error {
+	return &RouteError{Op: "insert", Prefix: route.Prefix, PathID: route.PathID, Err: err}
+}
+
+func deleteError(prefix netip.Prefix, pathID uint32, err error) error {
+	return &RouteError{Op: "delete", Prefix: prefix, PathID: pathID, Err: err}
+}
+
+// checkIPv4 validates the family and mask of an IPv4 prefix.
+func checkIPv4(prefix netip.Prefix) error {
+	if !prefix.Addr().Is4() {
+		return ErrWrongFamily
+	}
+	if mask := prefix.Bits(); mask < 8 || mask > 24 {
+		return ErrBadMask
+	}
+	return nil
+}
+
+// checkIPv6 validates the family, range and mask of an IPv6 prefix.
+func checkIPv6(prefix netip.Prefix) error {
+	if !prefix.Addr().Is6() {
+		return ErrWrongFamily
+	}
+	if !isGlobalUnicastV6(prefix.Addr()) {
+		return ErrOutsideGlobalUnicast
+	}
+	if mask := prefix.Bits(); mask < 8 || mask > 48 {
+		return ErrBadMask
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package routing_table_test
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestInsertDeleteErrors(t *testing.T) {
+	router := rib.GetNewRib()
+	router.SetBogonFilter(rib.DefaultBogons())
+	router.SetImportFilter(rib.MustParseFilter("not aspath contains 3356"))
+	router.SetImportPolicy(&rib.Policy{Terms: []rib.PolicyTerm{{Match: rib.MustParseFilter("aspath contains 1299"), Result: rib.PolicyReject}}})
+	router.SetLimits(&rib.Limits{MaxV4Prefixes: 2, Action: rib.LimitReject})
+
+	attr := &rib.RouteAttributes{AsPath: []uint32{174, 13335}}
+	route := func(p string, path ...uint32) rib.Route {
+		a := attr
+		if len(path) > 0 {
+			a = &rib.RouteAttributes{AsPath: path}
+		}
+		return rib.Route{Prefix: netip.MustParsePrefix(p), Attributes: a}
+	}
+
+	tests := []struct {
+		desc  string
+		route rib.Route
+		v6    bool
+		want  error
+	}{
+		{desc: "accepted", route: route("1.1.1.0/24")},
+		{desc: "v4 too long", route: route("1.1.1.0/25"), want: rib.ErrBadMask},
+		{desc: "v4 too short", route: route("1.0.0.0/7"), want: rib.ErrBadMask},
+		{desc: "v6 to v4", route: route("2001:db8::/32"), want: rib.ErrWrongFamily},
+		{desc: "bogon", route: route("10.0.0.0/8"), want: rib.ErrBogon},
+		{desc: "filtered", route: route("8.8.8.0/24", 174, 3356), want: rib.ErrFiltered},
+		{desc: "policy", route: route("8.8.8.0/24", 174, 1299), want: rib.ErrPolicyRejected},
+		{desc: "accepted second", route: route("8.8.8.0/24")},
+		{desc: "limit", route: route("9.9.9.0/24"), want: rib.ErrLimitExceeded},
+		{desc: "v6 accepted", route: route("2606:4700::/32"), v6: true},
+		{desc: "v6 outside", route: route("fc00::/8"), v6: true, want: rib.ErrOutsideGlobalUnicast},
+		{desc: "v6 too long", route: route("2606:4700::/64"), v6: true, want: rib.ErrBadMask},
+		{desc: "v4 to v6", route: route("1.1.1.0/24"), v6: true, want: rib.ErrWrongFamily},
+	}
+	for _, tc := range tests {
+		var err error
+		if tc.v6 {
+			err = router.InsertIPv6(tc.route)
+		} else {
+			err = router.InsertIPv4(tc.route)
+		}
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, err, tc.want)
+		}
+		var re *rib.RouteError
+		if tc.want != nil && (!errors.As(err, &re) || re.Op != "insert" || re.Prefix != tc.route.Prefix) {
+			t.Errorf("%s: expected *RouteError for the insert, got %#v", tc.desc, err)
+		}
+	}
+
+	if err := router.DeleteIPv4(netip.MustParsePrefix("1.1.1.0/24"), 0); err != nil {
+		t.Errorf("delete: unexpected error %v", err)
+	}
+	if err := router.DeleteIPv4(netip.MustParsePrefix("1.1.1.0/24"), 0); !errors.Is(err, rib.ErrNotFound) {
+		t.Errorf("second delete: got %v, want ErrNotFound", err)
+	}
+	if err := router.DeleteIPv6(netip.MustParsePrefix("2606:4700::/32"), 1); !errors.Is(err, rib.ErrNotFound) {
+		t.Errorf("delete unknown path: got %v, want ErrNotFound", err)
+	}
+	if err := router.DeleteIPv6(netip.MustParsePrefix("::/0"), 0); !errors.Is(err, rib.ErrOutsideGlobalUnicast) {
+		t.Errorf("delete ::/0: got %v, want ErrOutsideGlobalUnicast", err)
+	}
+	want := "delete 1.1.1.0/25 path 3: mask outside allowed range"
+	if err := router.DeleteIPv4(netip.MustParsePrefix("1.1.1.0/25"), 3); err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
+
+func TestBatchResults(t *testing.T) {
+	router := rib.GetNewRib()
+	errs := router.InsertIPv4BatchResults([]rib.Route{
+		{Prefix: netip.MustParsePrefix("1.1.1.0/24")},
+		{Prefix: netip.MustParsePrefix("1.1.1.0/28")},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32")},
+		{Prefix: netip.MustParsePrefix("1.0.0.0/24"), PathID: 2},
+	})
+	want := []error{nil, rib.ErrBadMask, rib.ErrWrongFamily, nil}
+	for i := range want {
+		if !errors.Is(errs[i], want[i]) {
+			t.Errorf("insert %d: got %v, want %v", i, errs[i], want[i])
+		}
+	}
+	if router.V4Count() != 2 {
+		t.Errorf("expected 2 prefixes, got %d", router.V4Count())
+	}
+
+	errs = router.InsertIPv6BatchResults([]rib.Route{
+		{Prefix: netip.MustParsePrefix("2001:db8::/32")},
+		{Prefix: netip.MustParsePrefix("1.1.1.0/24")},
+	})
+	if errs[0] != nil || !errors.Is(errs[1], rib.ErrWrongFamily) {
+		t.Errorf("unexpected IPv6 insert results %v", errs)
+	}
+
+	errs = router.DeleteIPv4BatchResults([]rib.PrefixWithID{
+		{Prefix: netip.MustParsePrefix("1.0.0.0/24"), PathID: 2},
+		{Prefix: netip.MustParsePrefix("1.0.0.0/24"), PathID: 2},
+		{Prefix: netip.MustParsePrefix("2001:db8::/32")},
+	})
+	want = []error{nil, rib.ErrNotFound, rib.ErrWrongFamily}
+	for i := range want {
+		if !errors.Is(errs[i], want[i]) {
+			t.Errorf("delete %d: got %v, want %v", i, errs[i], want[i])
+		}
+	}
+	errs = router.DeleteIPv6BatchResults([]rib.PrefixWithID{{Prefix: netip.MustParsePrefix("2001:db8::/32")}})
+	if errs[0] != nil || router.V6Count() != 0 {
+		t.Errorf("unexpected IPv6 delete results %v", errs)
+	}
+}
@@ -0,0 +1,473 @@
+package routing_table
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Filter is a compiled route filter expression. Filters can be used to query a
+// RIB (Rib.Query) or as an import policy (Rib.SetImportFilter).
+//
+// An expression is made of conditions joined with "and", "or", "not" and
+// parentheses, for example:
+//
+//	origin 15169 and prefixlen >= 24 and community 65000:100 and aspath length > 5
+//
+// Supported conditions, where <op> is one of = == != < <= > >= and defaults to "=":
+//
+//	origin [<op>] ASN             last ASN of the AS path; never matches an empty path
+//	prefixlen <op> N              prefix mask length
+//	localpref <op> N              LocalPref attribute
+//	pathid <op> N                 Add-Path path identifier
+//	aspath length <op> N          number of ASNs in the AS path
+//	aspath contains ASN           ASN appears anywhere in the AS path
+//	aspath matches "PATTERN"      Cisco-style pattern, or BIRD if it starts with "[="
+//	community A:B                 standard community (RFC 1997)
+//	large-community A:B:C         large community (RFC 8092)
+//	prefix [=] PREFIX             exact prefix
+//	prefix within PREFIX          prefix equal to or more specific than PREFIX
+//	family ipv4|ipv6              address family
+type Filter struct {
+	expr string
+	pred func(*Route) bool
+}
+
+// ParseFilter compiles a filter expression.
+func ParseFilter(expr string) (*Filter, error) {
+	toks, err := lexFilter(expr)
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", expr, err)
+	}
+	p := &filterParser{toks: toks}
+	pred, err := p.or()
+	if err == nil && !p.done() {
+		err = fmt.Errorf("unexpected %q", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filter %q: %w", expr, err)
+	}
+	return &Filter{expr: expr, pred: pred}, nil
+}
+
+// MustParseFilter is like ParseFilter but panics if the expression is invalid.
+func MustParseFilter(expr string) *Filter {
+	f, err := ParseFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// String returns the source expression.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Match reports whether the route satisfies the filter. A route with nil
+// attributes is treated as having empty attributes.
+func (f *Filter) Match(rt Route) bool {
+	if rt.Attributes == nil {
+		rt.Attributes = &RouteAttributes{}
+	}
+	return f.pred(&rt)
+}
+
+// Query walks the entire RIB and returns every IPv4 and IPv6 path matching f.
+func (r *Rib) Query(f *Filter) (v4 []Route, v6 []Route) {
+	r.v4mu.RLock()
+	r.walkIPv4(func(prefix netip.Prefix, n *node) {
+		for id := range n.paths {
+			rt := n.route(prefix, id)
+			if f.pred(&rt) {
+				v4 = append(v4, rt)
+			}
+		}
+	})
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(func(prefix netip.Prefix, n *node) {
+		for id := range n.paths {
+			rt := n.route(prefix, id)
+			if f.pred(&rt) {
+				v6 = append(v6, rt)
+			}
+		}
+	})
+	r.v6mu.RUnlock()
+
+	return v4, v6
+}
+
+// SetImportFilter installs f as an insert-time policy for both address
+// families. Routes that do not match are rejected; a rejected update also
+// withdraws any path previously stored for the same prefix and PathID, as a
+// BGP speaker would. Passing nil removes the filter. Routes already in the
+// RIB are not re-evaluated.
+func (r *Rib) SetImportFilter(f *Filter) {
+	r.v4mu.Lock()
+	r.v6mu.Lock()
+	defer r.v4mu.Unlock()
+	defer r.v6mu.Unlock()
+	r.importFilter = f
+}
+
+// importRejected reports whether the import filter rejects the route.
+// The caller must hold the lock of the route's address family.
+func (r *Rib) importRejected(route Route) bool {
+	return r.importFilter != nil && !r.importFilter.Match(route)
+}
+
+// filterToken is a lexical token of a filter expression.
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+func lexFilter(s string) ([]filterToken, error) {
+	var toks []filterToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			toks = append(toks, filterToken{text: s[i : i+1]})
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			toks = append(toks, filterToken{text: s[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		case strings.IndexByte("<>=!", c) >= 0:
+			j := i
+			for j < len(s) && strings.IndexByte("<>=!", s[j]) >= 0 {
+				j++
+			}
+			toks = append(toks, filterToken{text: s[i:j]})
+			i = j
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\n\r()\"<>=!", s[j]) < 0 {
+				j++
+			}
+			toks = append(toks, filterToken{text: s[i:j]})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type filterParser struct {
+	toks []filterToken
+	pos  int
+}
+
+func (p *filterParser) done() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *filterParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.toks[p.pos].text
+}
+
+// keyword consumes the next token if it is the unquoted word kw.
+func (p *filterParser) keyword(kw string) bool {
+	if p.done() || p.toks[p.pos].quoted || !strings.EqualFold(p.toks[p.pos].text, kw) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	if p.done() {
+		return filterToken{}, fmt.Errorf("unexpected end of expression")
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *filterParser) or() (func(*Route) bool, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(rt *Route) bool { return l(rt) || right(rt) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (func(*Route) bool, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(rt *Route) bool { return l(rt) && right(rt) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) unary() (func(*Route) bool, error) {
+	if p.keyword("not") {
+		sub, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(rt *Route) bool { return !sub(rt) }, nil
+	}
+	if p.keyword("(") {
+		sub, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(")") {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		return sub, nil
+	}
+	return p.condition()
+}
+
+func (p *filterParser) condition() (func(*Route) bool, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(t.text) {
+	case "origin":
+		cmp, n, err := p.comparison(true)
+		if err != nil {
+			return nil, err
+		}
+		return func(rt *Route) bool {
+			path := rt.Attributes.AsPath
+			return len(path) > 0 && cmp(uint64(path[len(path)-1]), n)
+		}, nil
+
+	case "prefixlen":
+		cmp, n, err := p.comparison(false)
+		if err != nil {
+			return nil, err
+		}
+		return func(rt *Route) bool { return cmp(uint64(rt.Prefix.Bits()), n) }, nil
+
+	case "localpref":
+		cmp, n, err := p.comparison(false)
+		if err != nil {
+			return nil, err
+		}
+		return func(rt *Route) bool { return cmp(uint64(rt.Attributes.LocalPref), n) }, nil
+
+	case "pathid":
+		cmp, n, err := p.comparison(false)
+		if err != nil {
+			return nil, err
+		}
+		return func(rt *Route) bool { return cmp(uint64(rt.PathID), n) }, nil
+
+	case "aspath":
+		return p.aspathCondition()
+
+	case "community":
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		comm, err := ParseCommunity(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return func(rt *Route) bool {
+			for _, c := range rt.Attributes.Communities {
+				if c == comm {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	case "large-community":
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		lc, err := ParseLargeCommunity(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return func(rt *Route) bool {
+			for _, c := range rt.Attributes.LargeCommunities {
+				if c == lc {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	case "prefix":
+		within := p.keyword("within")
+		if !within {
+			p.keyword("=")
+			p.keyword("==")
+		}
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		pfx, err := netip.ParsePrefix(t.text)
+		if err != nil {
+			return nil, err
+		}
+		pfx = pfx.Masked()
+		if within {
+			return func(rt *Route) bool {
+				return rt.Prefix.Bits() >= pfx.Bits() && pfx.Contains(rt.Prefix.Addr())
+			}, nil
+		}
+		return func(rt *Route) bool { return rt.Prefix.Masked() == pfx }, nil
+
+	case "family":
+		switch {
+		case p.keyword("ipv4"):
+			return func(rt *Route) bool { return rt.Prefix.Addr().Is4() }, nil
+		case p.keyword("ipv6"):
+			return func(rt *Route) bool { return rt.Prefix.Addr().Is6() }, nil
+		}
+		return nil, fmt.Errorf("family must be ipv4 or ipv6")
+	}
+	return nil, fmt.Errorf("unknown condition %q", t.text)
+}
+
+func (p *filterParser) aspathCondition() (func(*Route) bool, error) {
+	switch {
+	case p.keyword("length"):
+		cmp, n, err := p.comparison(false)
+		if err != nil {
+			return nil, err
+		}
+		return func(rt *Route) bool { return cmp(uint64(len(rt.Attributes.AsPath)), n) }, nil
+
+	case p.keyword("contains"):
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		asn, err := strconv.ParseUint(t.text, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ASN %q", t.text)
+		}
+		return func(rt *Route) bool {
+			for _, v := range rt.Attributes.AsPath {
+				if v == uint32(asn) {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	case p.keyword("matches"):
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		syntax := AsPathCisco
+		if strings.HasPrefix(strings.TrimSpace(t.text), "[=") {
+			syntax = AsPathBird
+		}
+		m, err := CompileAsPath(t.text, syntax)
+		if err != nil {
+			return nil, err
+		}
+		return func(rt *Route) bool { return m.Match(rt.Attributes.AsPath) }, nil
+	}
+	return nil, fmt.Errorf("aspath must be followed by length, contains or matches")
+}
+
+// comparison parses "[<op>] N". The operator may only be omitted when
+// optionalOp is set, in which case it defaults to equality.
+func (p *filterParser) comparison(optionalOp bool) (func(a, b uint64) bool, uint64, error) {
+	var cmp func(a, b uint64) bool
+	switch p.peek() {
+	case "=", "==":
+		cmp = func(a, b uint64) bool { return a == b }
+	case "!=":
+		cmp = func(a, b uint64) bool { return a != b }
+	case "<":
+		cmp = func(a, b uint64) bool { return a < b }
+	case "<=":
+		cmp = func(a, b uint64) bool { return a <= b }
+	case ">":
+		cmp = func(a, b uint64) bool { return a > b }
+	case ">=":
+		cmp = func(a, b uint64) bool { return a >= b }
+	}
+	if cmp != nil {
+		p.pos++
+	} else if optionalOp {
+		cmp = func(a, b uint64) bool { return a == b }
+	} else {
+		return nil, 0, fmt.Errorf("expected comparison operator, got %q", p.peek())
+	}
+
+	t, err := p.next()
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := strconv.ParseUint(t.text, 10, 32)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid number %q", t.text)
+	}
+	return cmp, n, nil
+}
+
+// ParseCommunity parses a standard community in "ASN:value" notation.
+func ParseCommunity(s string) (uint32, error) {
+	hi, lo, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid community %q", s)
+	}
+	a, err1 := strconv.ParseUint(hi, 10, 16)
+	b, err2 := strconv.ParseUint(lo, 10, 16)
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("invalid community %q", s)
+	}
+	return uint32(a)<<16 | uint32(b), nil
+}
+
+// ParseLargeCommunity parses a large community in "global:local1:local2" notation.
+func ParseLargeCommunity(s string) (LargeCommunity, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return LargeCommunity{}, fmt.Errorf("invalid large community %q", s)
+	}
+	var v [3]uint32
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return LargeCommunity{}, fmt.Errorf("invalid large community %q", s)
+		}
+		v[i] = uint32(n)
+	}
+	return LargeCommunity{GlobalAdmin: v[0], LocalData1: v[1], LocalData2: v[2]}, nil
+}
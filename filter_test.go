@@ -0,0 +1,132 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestFilterMatch(t *testing.T) {
+	comm, _ := rib.ParseCommunity("65000:100")
+	route := rib.Route{
+		Prefix: netip.MustParsePrefix("8.8.8.0/24"),
+		Attributes: &rib.RouteAttributes{
+			AsPath:           []uint32{65000, 3356, 3356, 3356, 174, 15169},
+			Communities:      []uint32{comm},
+			LargeCommunities: []rib.LargeCommunity{{GlobalAdmin: 65000, LocalData1: 1, LocalData2: 2}},
+			LocalPref:        200,
+		},
+		PathID: 7,
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"origin 15169 and prefixlen >= 24 and community 65000:100 and aspath length > 5", true},
+		{"origin 15169", true},
+		{"origin != 15169", false},
+		{"prefixlen < 24", false},
+		{"localpref = 200 and pathid == 7", true},
+		{"aspath contains 3356", true},
+		{"aspath contains 1299", false},
+		{`aspath matches "_174_15169$"`, true},
+		{`aspath matches "[= 65000 * =]"`, true},
+		{"large-community 65000:1:2", true},
+		{"community 65000:200", false},
+		{"prefix 8.8.8.0/24", true},
+		{"prefix within 8.0.0.0/8", true},
+		{"prefix within 9.0.0.0/8", false},
+		{"family ipv6", false},
+		{"not family ipv6", true},
+		{"origin 1 or (origin 15169 and localpref > 100)", true},
+		{"origin 1 or origin 2 and origin 15169", false},
+		{"NOT (origin 1 OR origin 2)", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			f, err := rib.ParseFilter(tc.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q): %v", tc.expr, err)
+			}
+			if got := f.Match(route); got != tc.want {
+				t.Errorf("Match = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"origin",
+		"origin 15169 and",
+		"prefixlen 24",
+		"(origin 1",
+		"community 65536:1",
+		"aspath length",
+		"aspath foo 1",
+		`aspath matches "(1"`,
+		"prefix 10.0.0.0",
+		"family ipx",
+		"bogus 1",
+		`origin "1`,
+	} {
+		if _, err := rib.ParseFilter(expr); err == nil {
+			t.Errorf("expected error parsing %q", expr)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	router := rib.GetNewRib()
+	google := &rib.RouteAttributes{AsPath: []uint32{3356, 15169}}
+	other := &rib.RouteAttributes{AsPath: []uint32{3356, 13335}}
+
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("8.8.8.0/24"), Attributes: google})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("8.0.0.0/9"), Attributes: google})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24"), Attributes: other})
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:4860::/32"), Attributes: google})
+
+	v4, v6 := router.Query(rib.MustParseFilter("origin 15169 and prefixlen >= 24 and family ipv4"))
+	if len(v4) != 1 || v4[0].Prefix != netip.MustParsePrefix("8.8.8.0/24") {
+		t.Errorf("expected only 8.8.8.0/24, got %v", v4)
+	}
+	if len(v6) != 0 {
+		t.Errorf("expected no v6 routes, got %v", v6)
+	}
+
+	v4, v6 = router.Query(rib.MustParseFilter("origin 15169"))
+	if len(v4) != 2 || len(v6) != 1 {
+		t.Errorf("expected 2 v4 and 1 v6 routes, got %d and %d", len(v4), len(v6))
+	}
+}
+
+func TestImportFilter(t *testing.T) {
+	router := rib.GetNewRib()
+	prefix := netip.MustParsePrefix("10.0.0.0/24")
+
+	router.InsertIPv4(rib.Route{Prefix: prefix, Attributes: &rib.RouteAttributes{AsPath: []uint32{1, 2}}})
+	router.SetImportFilter(rib.MustParseFilter("aspath length <= 3"))
+
+	// Rejected: too long, and it withdraws the previously accepted path.
+	router.InsertIPv4(rib.Route{Prefix: prefix, Attributes: &rib.RouteAttributes{AsPath: []uint32{1, 2, 3, 4}}})
+	if router.V4Count() != 0 {
+		t.Errorf("expected rejected update to withdraw the prefix, have %d prefixes", router.V4Count())
+	}
+
+	added := router.InsertIPv6Batch([]rib.Route{
+		{Prefix: netip.MustParsePrefix("2001:db8::/32"), Attributes: &rib.RouteAttributes{AsPath: []uint32{1}}},
+		{Prefix: netip.MustParsePrefix("2001:db9::/32"), Attributes: &rib.RouteAttributes{AsPath: []uint32{1, 2, 3, 4}}},
+	})
+	if len(added) != 1 || router.V6Count() != 1 {
+		t.Errorf("expected 1 v6 prefix accepted, got %v (count %d)", added, router.V6Count())
+	}
+
+	router.SetImportFilter(nil)
+	router.InsertIPv4(rib.Route{Prefix: prefix, Attributes: &rib.RouteAttributes{AsPath: []uint32{1, 2, 3, 4}}})
+	if router.V4Count() != 1 {
+		t.Errorf("expected route accepted after removing filter")
+	}
+}
@@ -0,0 +1,135 @@
+package routing_table
+
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// MarkStale marks every path in the RIB as stale, as when the peer it was
+// learned from restarts with graceful restart (RFC 4724) or long-lived
+// graceful restart. Stale paths stay usable for forwarding but lose best
+// path selection to any fresh path. A path is unmarked when it is
+// re-announced, and paths still stale are removed by SweepStale. It returns
+// the number of paths marked.
+func (r *Rib) MarkStale() int {
+	return r.markStale(nil)
+}
+
+// SweepStale removes every path still marked stale, as on End-of-RIB from the
+// restarted peer, and returns the removed paths, IPv4 before IPv6 in address
+// order.
+func (r *Rib) SweepStale() []PrefixWithID {
+	return r.sweepStale(nil)
+}
+
+// SweepStaleAfter calls SweepStale once d has elapsed, as when the restart
+// or stale timer expires. Stop the returned timer if End-of-RIB arrives
+// first.
+func (r *Rib) SweepStaleAfter(d time.Duration) *time.Timer {
+	return time.AfterFunc(d, func() { r.SweepStale() })
+}
+
+// markStale marks the paths whose PathID match accepts, or all paths if
+// match is nil, and returns the number marked.
+func (r *Rib) markStale(match func(pathID uint32) bool) int {
+	marked := 0
+	mark := func(_ netip.Prefix, n *node) {
+		for id := range n.paths {
+			if match == nil || match(id) {
+				n.pathMeta(id, true).stale = true
+				marked++
+			}
+		}
+	}
+
+	r.v4mu.Lock()
+	r.walkIPv4(mark)
+	r.v4mu.Unlock()
+
+	r.v6mu.Lock()
+	r.walkIPv6(mark)
+	r.v6mu.Unlock()
+
+	return marked
+}
+
+// sweepStale removes the stale paths whose PathID match accepts, or all
+// stale paths if match is nil.
+func (r *Rib) sweepStale(match func(pathID uint32) bool) []PrefixWithID {
+	var v4, v6 []PrefixWithID
+	collect := func(swept *[]PrefixWithID) func(netip.Prefix, *node) {
+		return func(prefix netip.Prefix, n *node) {
+			if n.meta == nil {
+				return
+			}
+			start := len(*swept)
+			for id := range n.paths {
+				if n.stale(id) && (match == nil || match(id)) {
+					*swept = append(*swept, PrefixWithID{prefix, id})
+				}
+			}
+			slices.SortFunc((*swept)[start:], func(a, b PrefixWithID) int { return cmp.Compare(a.PathID, b.PathID) })
+		}
+	}
+
+	r.v4mu.Lock()
+	r.walkIPv4(collect(&v4))
+	for _, p := range v4 {
+		r.deleteIPv4Unlocked(p.Prefix, p.PathID)
+	}
+	r.v4mu.Unlock()
+
+	r.v6mu.Lock()
+	r.walkIPv6(collect(&v6))
+	for _, p := range v6 {
+		r.deleteIPv6Unlocked(p.Prefix, p.PathID)
+	}
+	r.v6mu.Unlock()
+
+	return append(v4, v6...)
+}
+
+// MarkPeerStale marks every path from the peer at addr as stale, in its
+// Adj-RIB-In and the Loc-RIB, when the peer restarts. Paths are unmarked as
+// the peer re-announces them. It returns the number of paths marked.
+func (p *PeerRib) MarkPeerStale(addr netip.Addr) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st, ok := p.peers[addr]
+	if !ok {
+		return 0, ErrUnknownPeer
+	}
+	p.loc.markStale(p.ownedBy(addr))
+	return st.adjIn.MarkStale(), nil
+}
+
+// SweepPeerStale removes the paths from the peer at addr that are still
+// stale, as on its End-of-RIB, and returns the removed paths of its
+// Adj-RIB-In.
+func (p *PeerRib) SweepPeerStale(addr netip.Addr) ([]PrefixWithID, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st, ok := p.peers[addr]
+	if !ok {
+		return nil, ErrUnknownPeer
+	}
+	p.loc.sweepStale(p.ownedBy(addr))
+	return st.adjIn.SweepStale(), nil
+}
+
+// SweepPeerStaleAfter calls SweepPeerStale once d has elapsed. Stop the
+// returned timer if End-of-RIB arrives first.
+func (p *PeerRib) SweepPeerStaleAfter(addr netip.Addr, d time.Duration) *time.Timer {
+	return time.AfterFunc(d, func() { p.SweepPeerStale(addr) })
+}
+
+// ownedBy returns a matcher for the Loc-RIB PathIDs of the peer at addr. The
+// caller must hold p.mu.
+func (p *PeerRib) ownedBy(addr netip.Addr) func(uint32) bool {
+	return func(id uint32) bool {
+		key, ok := p.paths[id]
+		return ok && key.addr == addr
+	}
+}
@@ -0,0 +1,98 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestGracefulRestart(t *testing.T) {
+	router := rib.GetNewRib()
+	p4 := netip.MustParsePrefix("1.1.1.0/24")
+	p6 := netip.MustParsePrefix("2606:4700::/32")
+	short := &rib.RouteAttributes{AsPath: []uint32{13335}}
+	long := &rib.RouteAttributes{AsPath: []uint32{174, 3356, 13335}}
+
+	router.InsertIPv4(rib.Route{Prefix: p4, Attributes: short})
+	router.InsertIPv4(rib.Route{Prefix: p4, Attributes: long, PathID: 1})
+	router.InsertIPv6(rib.Route{Prefix: p6, Attributes: short})
+
+	if n := router.MarkStale(); n != 3 {
+		t.Errorf("expected 3 paths marked, got %d", n)
+	}
+	if got := router.LookupIPv4(p4); got == nil || !got.Stale || got.Attributes.AsPath[0] != 13335 {
+		t.Errorf("expected stale best path kept, got %+v", got)
+	}
+
+	// Re-announcing the longer path makes it fresh, so it now wins.
+	router.InsertIPv4(rib.Route{Prefix: p4, Attributes: long, PathID: 1})
+	got := router.LookupIPv4(p4)
+	if got.Stale || len(got.Attributes.AsPath) != 3 {
+		t.Errorf("expected fresh path preferred, got %+v", got)
+	}
+	if best := rib.SelectBest(router.AllPathsIPv4(p4)); best.PathID != 1 {
+		t.Errorf("SelectBest: expected fresh path 1, got %d", best.PathID)
+	}
+
+	swept := router.SweepStale()
+	want := []rib.PrefixWithID{{Prefix: p4, PathID: 0}, {Prefix: p6, PathID: 0}}
+	if len(swept) != 2 || swept[0] != want[0] || swept[1] != want[1] {
+		t.Errorf("unexpected swept paths %v", swept)
+	}
+	if router.V4PathCount() != 1 || router.V6Count() != 0 {
+		t.Errorf("expected only the re-announced path left, got %d/%d", router.V4PathCount(), router.V6Count())
+	}
+	if paths := router.AllPathsIPv4(p4); paths[0].Stale {
+		t.Error("expected surviving path not stale")
+	}
+
+	router.MarkStale()
+	timer := router.SweepStaleAfter(time.Millisecond)
+	defer timer.Stop()
+	for deadline := time.Now().Add(5 * time.Second); router.V4Count() != 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if router.V4Count() != 0 {
+		t.Error("expected timer to sweep stale paths")
+	}
+}
+
+func TestPeerGracefulRestart(t *testing.T) {
+	pr := rib.NewPeerRib()
+	a := rib.Peer{ASN: 174, Address: netip.MustParseAddr("192.0.2.1")}
+	b := rib.Peer{ASN: 3356, Address: netip.MustParseAddr("192.0.2.2")}
+	pr.AddPeer(a)
+	pr.AddPeer(b)
+
+	p1 := netip.MustParsePrefix("1.1.1.0/24")
+	p2 := netip.MustParsePrefix("8.8.8.0/24")
+	pr.Insert(a.Address, rib.Route{Prefix: p1, Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 13335}}})
+	pr.Insert(a.Address, rib.Route{Prefix: p2, Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 15169}}})
+	pr.Insert(b.Address, rib.Route{Prefix: p1, Attributes: &rib.RouteAttributes{AsPath: []uint32{3356, 1299, 13335}}})
+
+	if n, err := pr.MarkPeerStale(a.Address); n != 2 || err != nil {
+		t.Fatalf("expected 2 paths marked, got %d %v", n, err)
+	}
+	// Peer a's shorter path is stale, so peer b's path wins meanwhile.
+	if best, _ := pr.Best(p1); best.Peer != b {
+		t.Errorf("expected fresh path from peer b, got %+v", best)
+	}
+	if best, _ := pr.Best(p2); best.Peer != a || !best.Route.Stale {
+		t.Errorf("expected stale path kept for 8.8.8.0/24, got %+v", best)
+	}
+
+	pr.Insert(a.Address, rib.Route{Prefix: p1, Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 13335}}})
+	if best, _ := pr.Best(p1); best.Peer != a || best.Route.Stale {
+		t.Errorf("expected re-announced path from peer a, got %+v", best)
+	}
+
+	swept, err := pr.SweepPeerStale(a.Address)
+	if err != nil || len(swept) != 1 || swept[0].Prefix != p2 {
+		t.Errorf("unexpected sweep %v %v", swept, err)
+	}
+	if _, ok := pr.Best(p2); ok || pr.LocRib().V4PathCount() != 2 {
+		t.Errorf("expected 8.8.8.0/24 gone from the Loc-RIB, %d paths left", pr.LocRib().V4PathCount())
+	}
+}
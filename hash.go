@@ -2,11 +2,23 @@ package routing_table
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
-// attrTable manages deduplication of RouteAttributes.
+// attrShards is the number of independently locked shards in an attrTable,
+// so that IPv4 and IPv6 inserts running under their own locks rarely contend
+// on the attribute table.
+const attrShards = 64
+
+// attrTable manages deduplication of RouteAttributes. Attributes are spread
+// over shards by hash. Reference counts are atomic, so a release that does
+// not drop the last reference takes no lock.
 type attrTable struct {
-	mu      sync.Mutex
+	shards []attrShard
+}
+
+type attrShard struct {
+	mu      sync.RWMutex
 	entries map[uint64][]*RouteAttributes
 
 	attrCount  uint64
@@ -14,9 +26,16 @@ type attrTable struct {
 }
 
 func newAttrTable() *attrTable {
-	return &attrTable{
-		entries: make(map[uint64][]*RouteAttributes),
+	at := &attrTable{shards: make([]attrShard, attrShards)}
+	for i := range at.shards {
+		at.shards[i].entries = make(map[uint64][]*RouteAttributes)
 	}
+	return at
+}
+
+func (at *attrTable) shard(h uint64) *attrShard {
+	// The low bits of an FNV hash are well mixed.
+	return &at.shards[h&uint64(len(at.shards)-1)]
 }
 
 // fnv-1a 64-bit hash
@@ -90,15 +109,25 @@ func (at *attrTable) getOrInsert(attr *RouteAttributes) *RouteAttributes {
 	if attr == nil {
 		attr = &RouteAttributes{}
 	}
-	at.mu.Lock()
-	defer at.mu.Unlock()
-
 	h := hashAttributes(attr)
-	for _, existing := range at.entries[h] {
-		if equalAttributes(existing, attr) {
-			existing.refCount++
-			return existing
-		}
+	sh := at.shard(h)
+
+	// Existing attributes only need the read lock. Removal of an entry whose
+	// count dropped to zero takes the write lock, so an entry found here is
+	// still in the table and may be revived.
+	sh.mu.RLock()
+	if existing := sh.find(h, attr); existing != nil {
+		atomic.AddUint32(&existing.refCount, 1)
+		sh.mu.RUnlock()
+		return existing
+	}
+	sh.mu.RUnlock()
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if existing := sh.find(h, attr); existing != nil {
+		atomic.AddUint32(&existing.refCount, 1)
+		return existing
 	}
 
 	// Not found, create deep copy
@@ -120,39 +149,67 @@ func (at *attrTable) getOrInsert(attr *RouteAttributes) *RouteAttributes {
 		copy(copyAttr.LargeCommunities, attr.LargeCommunities)
 	}
 
-	at.entries[h] = append(at.entries[h], copyAttr)
-	at.attrCount++
-	at.sliceBytes += uint64(len(copyAttr.AsPath)*4 + len(copyAttr.Communities)*4 + len(copyAttr.LargeCommunities)*12)
+	sh.entries[h] = append(sh.entries[h], copyAttr)
+	sh.attrCount++
+	sh.sliceBytes += attrSliceBytes(copyAttr)
 	return copyAttr
 }
 
+// find returns the interned attributes equal to attr, or nil. The caller
+// must hold sh.mu.
+func (sh *attrShard) find(h uint64, attr *RouteAttributes) *RouteAttributes {
+	for _, existing := range sh.entries[h] {
+		if equalAttributes(existing, attr) {
+			return existing
+		}
+	}
+	return nil
+}
+
 func (at *attrTable) release(attr *RouteAttributes) {
 	if attr == nil {
 		return
 	}
-	at.mu.Lock()
-	defer at.mu.Unlock()
-
-	attr.refCount--
-	if attr.refCount == 0 {
-		list := at.entries[attr.hash]
-		for i, existing := range list {
-			if existing == attr { // pointer equality is safe here
-				at.entries[attr.hash] = append(list[:i], list[i+1:]...)
-				if len(at.entries[attr.hash]) == 0 {
-					delete(at.entries, attr.hash)
-				}
-				at.attrCount--
-				at.sliceBytes -= uint64(len(attr.AsPath)*4 + len(attr.Communities)*4 + len(attr.LargeCommunities)*12)
-				return
+	if atomic.AddUint32(&attr.refCount, ^uint32(0)) != 0 {
+		return
+	}
+
+	sh := at.shard(attr.hash)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	// A getOrInsert may have revived the entry before the lock was taken,
+	// or a concurrent release already removed it.
+	if atomic.LoadUint32(&attr.refCount) != 0 {
+		return
+	}
+	list := sh.entries[attr.hash]
+	for i, existing := range list {
+		if existing == attr { // pointer equality is safe here
+			sh.entries[attr.hash] = append(list[:i], list[i+1:]...)
+			if len(sh.entries[attr.hash]) == 0 {
+				delete(sh.entries, attr.hash)
 			}
+			sh.attrCount--
+			sh.sliceBytes -= attrSliceBytes(attr)
+			return
 		}
 	}
 }
 
+// attrSliceBytes returns the size of the slices backing attr.
+func attrSliceBytes(attr *RouteAttributes) uint64 {
+	return uint64(len(attr.AsPath)*4 + len(attr.Communities)*4 + len(attr.LargeCommunities)*12)
+}
+
 // GetStats returns the current number of unique attributes and the bytes used by their slices
 func (at *attrTable) GetStats() (uint64, uint64) {
-	at.mu.Lock()
-	defer at.mu.Unlock()
-	return at.attrCount, at.sliceBytes
+	var attrCount, sliceBytes uint64
+	for i := range at.shards {
+		sh := &at.shards[i]
+		sh.mu.RLock()
+		attrCount += sh.attrCount
+		sliceBytes += sh.sliceBytes
+		sh.mu.RUnlock()
+	}
+	return attrCount, sliceBytes
 }
@@ -0,0 +1,290 @@
+package routing_table
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IRRDatabase holds the route, route6, as-set and aut-num objects loaded from
+// RPSL database dumps, such as the files published by RADB, RIPE or ARIN.
+type IRRDatabase struct {
+	routes  map[uint32]map[netip.Prefix]struct{}
+	asSets  map[string][]string
+	autNums map[uint32]string
+}
+
+// IRRRoute is a route or route6 object: origin is registered to announce prefix.
+type IRRRoute struct {
+	Prefix netip.Prefix
+	Origin uint32
+}
+
+// NewIRRDatabase returns an empty database.
+func NewIRRDatabase() *IRRDatabase {
+	return &IRRDatabase{
+		routes:  make(map[uint32]map[netip.Prefix]struct{}),
+		asSets:  make(map[string][]string),
+		autNums: make(map[uint32]string),
+	}
+}
+
+// Load parses an RPSL dump and adds its route, route6, as-set and aut-num
+// objects to the database; other object classes are ignored. Load may be
+// called once per dump to combine several registries. Objects whose key or
+// origin does not parse are skipped, as dumps routinely contain a few.
+func (db *IRRDatabase) Load(rd io.Reader) error {
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var obj []rpslAttr
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			db.addObject(obj)
+			obj = obj[:0]
+		case line[0] == '%' || line[0] == '#':
+		case line[0] == ' ' || line[0] == '\t' || line[0] == '+':
+			// Continuation of the previous attribute's value.
+			if len(obj) > 0 {
+				obj[len(obj)-1].value += " " + rpslValue(line[1:])
+			}
+		default:
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			obj = append(obj, rpslAttr{strings.ToLower(strings.TrimSpace(key)), rpslValue(value)})
+		}
+	}
+	db.addObject(obj)
+	return sc.Err()
+}
+
+type rpslAttr struct {
+	key   string
+	value string
+}
+
+// rpslValue strips an end-of-line comment and surrounding space from a value.
+func rpslValue(s string) string {
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+func (db *IRRDatabase) addObject(obj []rpslAttr) {
+	if len(obj) == 0 {
+		return
+	}
+	class, key := obj[0].key, obj[0].value
+
+	switch class {
+	case "route", "route6":
+		prefix, err := netip.ParsePrefix(key)
+		if err != nil {
+			return
+		}
+		for _, a := range obj[1:] {
+			if a.key != "origin" {
+				continue
+			}
+			if origin, ok := parseRPSLASN(a.value); ok {
+				if db.routes[origin] == nil {
+					db.routes[origin] = make(map[netip.Prefix]struct{})
+				}
+				db.routes[origin][prefix.Masked()] = struct{}{}
+			}
+		}
+
+	case "as-set":
+		name := strings.ToUpper(key)
+		members := db.asSets[name]
+		for _, a := range obj[1:] {
+			if a.key != "members" {
+				continue
+			}
+			for _, m := range strings.FieldsFunc(a.value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+				members = append(members, strings.ToUpper(m))
+			}
+		}
+		db.asSets[name] = members
+
+	case "aut-num":
+		asn, ok := parseRPSLASN(key)
+		if !ok {
+			return
+		}
+		var name string
+		for _, a := range obj[1:] {
+			if a.key == "as-name" {
+				name = a.value
+				break
+			}
+		}
+		db.autNums[asn] = name
+	}
+}
+
+// parseRPSLASN parses an "AS65000" style ASN, case-insensitively.
+func parseRPSLASN(s string) (uint32, bool) {
+	if len(s) < 3 || !strings.EqualFold(s[:2], "AS") {
+		return 0, false
+	}
+	asn, err := strconv.ParseUint(s[2:], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(asn), true
+}
+
+// ASName returns the as-name of an aut-num object, and whether one exists.
+func (db *IRRDatabase) ASName(asn uint32) (string, bool) {
+	name, ok := db.autNums[asn]
+	return name, ok
+}
+
+// Routes returns the prefixes registered with origin asn, IPv4 before IPv6,
+// in address order.
+func (db *IRRDatabase) Routes(asn uint32) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(db.routes[asn]))
+	for p := range db.routes[asn] {
+		prefixes = append(prefixes, p)
+	}
+	sortPrefixes(prefixes)
+	return prefixes
+}
+
+// ExpandASSet recursively expands an as-set into its member ASNs in ascending
+// order. object may also be a single ASN such as "AS65000". Sets that are
+// referenced but not in the database contribute nothing, and a set reached
+// again through a loop is only expanded once.
+func (db *IRRDatabase) ExpandASSet(object string) ([]uint32, error) {
+	object = strings.ToUpper(strings.TrimSpace(object))
+	if asn, ok := parseRPSLASN(object); ok {
+		return []uint32{asn}, nil
+	}
+	if _, ok := db.asSets[object]; !ok {
+		return nil, fmt.Errorf("as-set %s not found", object)
+	}
+
+	var asns []uint32
+	visited := make(map[string]bool)
+	var expand func(name string)
+	expand = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, m := range db.asSets[name] {
+			if asn, ok := parseRPSLASN(m); ok {
+				asns = append(asns, asn)
+			} else {
+				expand(m)
+			}
+		}
+	}
+	expand(object)
+
+	slices.Sort(asns)
+	return slices.Compact(asns), nil
+}
+
+// PrefixList returns a prefix-list permitting exactly the prefixes registered
+// for the ASN or as-set object, named after it.
+func (db *IRRDatabase) PrefixList(object string) (*PrefixList, error) {
+	asns, err := db.ExpandASSet(object)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []netip.Prefix
+	for _, asn := range asns {
+		for p := range db.routes[asn] {
+			prefixes = append(prefixes, p)
+		}
+	}
+	sortPrefixes(prefixes)
+
+	pl := NewPrefixList(strings.ToUpper(object))
+	for i, p := range prefixes {
+		if i > 0 && p == prefixes[i-1] {
+			continue
+		}
+		if err := pl.Add(PrefixListEntry{Prefix: p}); err != nil {
+			return nil, err
+		}
+	}
+	return pl, nil
+}
+
+// IRRComparison is the difference between the routes registered for an ASN
+// or as-set and the routes the RIB holds for its ASNs.
+type IRRComparison struct {
+	// Unregistered holds paths originated by one of the ASNs for which no
+	// route object with that prefix and origin exists.
+	Unregistered []Route
+	// Unannounced holds route objects with no path in the RIB for the same
+	// prefix and origin.
+	Unannounced []IRRRoute
+}
+
+// CompareIRR expands the ASN or as-set object and compares its registered
+// route objects against the paths the RIB holds originated by its ASNs. Both
+// lists are IPv4 before IPv6, in address order.
+func (r *Rib) CompareIRR(db *IRRDatabase, object string) (IRRComparison, error) {
+	asns, err := db.ExpandASSet(object)
+	if err != nil {
+		return IRRComparison{}, err
+	}
+	origins := make(map[uint32]bool, len(asns))
+	for _, asn := range asns {
+		origins[asn] = true
+	}
+
+	var cmp IRRComparison
+	announced := make(map[IRRRoute]bool)
+	check := func(prefix netip.Prefix, n *node) {
+		for _, rt := range sortedRoutes(n, prefix) {
+			origin, ok := pathOrigin(rt.Attributes)
+			if !ok || !origins[origin] {
+				continue
+			}
+			announced[IRRRoute{prefix, origin}] = true
+			if _, registered := db.routes[origin][prefix]; !registered {
+				cmp.Unregistered = append(cmp.Unregistered, rt)
+			}
+		}
+	}
+
+	r.v4mu.RLock()
+	r.walkIPv4(check)
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(check)
+	r.v6mu.RUnlock()
+
+	for _, asn := range asns {
+		for p := range db.routes[asn] {
+			if !announced[IRRRoute{p, asn}] {
+				cmp.Unannounced = append(cmp.Unannounced, IRRRoute{p, asn})
+			}
+		}
+	}
+	sort.Slice(cmp.Unannounced, func(i, j int) bool {
+		a, b := cmp.Unannounced[i], cmp.Unannounced[j]
+		if c := comparePrefixes(a.Prefix, b.Prefix); c != 0 {
+			return c < 0
+		}
+		return a.Origin < b.Origin
+	})
+	return cmp, nil
+}
@@ -0,0 +1,125 @@
+package routing_table_test
+
+import (
+	"bytes"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+const rpslDump = `% RIPE-style header comment
+
+route:          20.0.0.0/16
+descr:          example
+origin:         AS64500
+source:         TEST
+
+route:          20.1.0.0/16
+origin:         as64500 # lower case with a comment
+
+route6:         2001:db8::/32
+origin:         AS64501
+
+route:          30.0.0.0/8
+origin:         AS64502
+
+route:          not-a-prefix
+origin:         AS64500
+
+as-set:         AS-CUSTOMERS
+members:        AS64500,
+                AS64501, AS-NESTED
++
+members:        AS-MISSING
+
+as-set:         AS-NESTED
+members:        AS64502, AS-CUSTOMERS
+
+aut-num:        AS64500
+as-name:        EXAMPLE-NET
+`
+
+func loadRPSL(t *testing.T) *rib.IRRDatabase {
+	t.Helper()
+	db := rib.NewIRRDatabase()
+	if err := db.Load(strings.NewReader(rpslDump)); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestIRRDatabase(t *testing.T) {
+	db := loadRPSL(t)
+
+	if got := db.Routes(64500); len(got) != 2 || got[1] != netip.MustParsePrefix("20.1.0.0/16") {
+		t.Errorf("unexpected routes for AS64500: %v", got)
+	}
+	if name, ok := db.ASName(64500); !ok || name != "EXAMPLE-NET" {
+		t.Errorf("unexpected as-name %q", name)
+	}
+
+	asns, err := db.ExpandASSet("as-customers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint32{64500, 64501, 64502}; !reflect.DeepEqual(asns, want) {
+		t.Errorf("got %v, want %v", asns, want)
+	}
+	if asns, _ := db.ExpandASSet("AS65000"); !reflect.DeepEqual(asns, []uint32{65000}) {
+		t.Errorf("expected a single ASN to expand to itself, got %v", asns)
+	}
+	if _, err := db.ExpandASSet("AS-UNKNOWN"); err == nil {
+		t.Error("expected error for unknown as-set")
+	}
+
+	pl, err := db.PrefixList("AS-CUSTOMERS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := pl.Write(&buf, rib.PrefixListCisco); err != nil {
+		t.Fatal(err)
+	}
+	want := `ip prefix-list AS-CUSTOMERS seq 5 permit 20.0.0.0/16
+ip prefix-list AS-CUSTOMERS seq 10 permit 20.1.0.0/16
+ip prefix-list AS-CUSTOMERS seq 15 permit 30.0.0.0/8
+ipv6 prefix-list AS-CUSTOMERS seq 20 permit 2001:db8::/32
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestCompareIRR(t *testing.T) {
+	db := loadRPSL(t)
+	router := rib.GetNewRib()
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("20.0.0.0/16"), Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 64500}}})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("20.0.1.0/24"), Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 64500}}})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("30.0.0.0/8"), Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 64501}}})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("40.0.0.0/8"), Attributes: &rib.RouteAttributes{AsPath: []uint32{174}}})
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32"), Attributes: &rib.RouteAttributes{AsPath: []uint32{64501}}})
+
+	cmp, err := router.CompareIRR(db, "AS-CUSTOMERS")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var unregistered []string
+	for _, rt := range cmp.Unregistered {
+		unregistered = append(unregistered, rt.Prefix.String())
+	}
+	if want := []string{"20.0.1.0/24", "30.0.0.0/8"}; !reflect.DeepEqual(unregistered, want) {
+		t.Errorf("unregistered: got %v, want %v", unregistered, want)
+	}
+
+	want := []rib.IRRRoute{
+		{Prefix: netip.MustParsePrefix("20.1.0.0/16"), Origin: 64500},
+		{Prefix: netip.MustParsePrefix("30.0.0.0/8"), Origin: 64502},
+	}
+	if !reflect.DeepEqual(cmp.Unannounced, want) {
+		t.Errorf("unannounced: got %v, want %v", cmp.Unannounced, want)
+	}
+}
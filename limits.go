@@ -0,0 +1,236 @@
+package routing_table
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// LimitAction is a set of actions taken when a limit is reached.
+type LimitAction uint8
+
+const (
+	// LimitLog logs warnings and the first insert exceeding a limit to the
+	// RIB's logger, at warn and error level respectively.
+	LimitLog LimitAction = 1 << iota
+	// LimitReject rejects inserts that would add a prefix or path beyond a
+	// limit. Updates to existing paths are always accepted.
+	LimitReject
+	// LimitCallback calls Limits.OnLimit for warnings and exceeded limits.
+	LimitCallback
+)
+
+// LimitResource identifies the counter a limit applies to.
+type LimitResource uint8
+
+// The counters limits apply to. A path is one Add-Path ID of a prefix.
+const (
+	LimitV4Prefixes LimitResource = iota
+	LimitV4Paths
+	LimitV6Prefixes
+	LimitV6Paths
+)
+
+func (l LimitResource) String() string {
+	switch l {
+	case LimitV4Prefixes:
+		return "IPv4 prefixes"
+	case LimitV4Paths:
+		return "IPv4 paths"
+	case LimitV6Prefixes:
+		return "IPv6 prefixes"
+	case LimitV6Paths:
+		return "IPv6 paths"
+	}
+	return fmt.Sprintf("LimitResource(%d)", uint8(l))
+}
+
+// LimitEventKind distinguishes warnings from exceeded limits.
+type LimitEventKind uint8
+
+const (
+	// LimitWarning is reported when a counter reaches the warning threshold.
+	LimitWarning LimitEventKind = iota
+	// LimitExceeded is reported for the first insert that would take a
+	// counter past its limit, and again after it has dropped below the limit.
+	LimitExceeded
+)
+
+func (k LimitEventKind) String() string {
+	switch k {
+	case LimitWarning:
+		return "warning"
+	case LimitExceeded:
+		return "exceeded"
+	}
+	return fmt.Sprintf("LimitEventKind(%d)", uint8(k))
+}
+
+// LimitEvent describes a limit warning or violation, with the insert that
+// triggered it.
+type LimitEvent struct {
+	Kind     LimitEventKind
+	Resource LimitResource
+	Count    int
+	Limit    int
+	Route    Route
+}
+
+func (e LimitEvent) String() string {
+	return fmt.Sprintf("%s limit %s at %d/%d by %s", e.Resource, e.Kind, e.Count, e.Limit, e.Route.Prefix)
+}
+
+// Limits caps the number of prefixes and paths a RIB accepts per address
+// family. A zero maximum means no limit.
+type Limits struct {
+	MaxV4Prefixes int
+	MaxV4Paths    int
+	MaxV6Prefixes int
+	MaxV6Paths    int
+
+	// WarnPercent, when non-zero, reports a warning each time a counter
+	// rises to this percentage of its limit, rounded up to a whole count.
+	WarnPercent int
+
+	Action LimitAction
+
+	// OnLimit is called for every event when Action includes LimitCallback.
+	// It runs with the write lock of the route's address family held, so it
+	// must not call back into the RIB.
+	OnLimit func(LimitEvent)
+}
+
+// LimitStats counts limit events since the limits were installed.
+type LimitStats struct {
+	V4Warnings uint64
+	V4Exceeded uint64
+	V4Rejected uint64
+	V6Warnings uint64
+	V6Exceeded uint64
+	V6Rejected uint64
+}
+
+// limitState is the per-family enforcement state, guarded by that family's lock.
+type limitState struct {
+	warnings uint64
+	exceeded uint64
+	rejected uint64
+	// over records, for prefixes [0] and paths [1], that the limit has been
+	// reported as exceeded and not yet dropped below.
+	over [2]bool
+}
+
+// SetLimits installs limits for both address families, enforced by every
+// insert method. Passing nil removes them. Installing limits resets the
+// counters returned by LimitStats; routes already in the RIB are kept even if
+// they exceed the new limits.
+func (r *Rib) SetLimits(l *Limits) {
+	r.v4mu.Lock()
+	r.v6mu.Lock()
+	defer r.v4mu.Unlock()
+	defer r.v6mu.Unlock()
+	r.limits = l
+	r.v4Limit = limitState{}
+	r.v6Limit = limitState{}
+}
+
+// LimitStats returns the limit counters for both address families.
+func (r *Rib) LimitStats() LimitStats {
+	r.v4mu.RLock()
+	r.v6mu.RLock()
+	defer r.v4mu.RUnlock()
+	defer r.v6mu.RUnlock()
+	return LimitStats{
+		V4Warnings: r.v4Limit.warnings,
+		V4Exceeded: r.v4Limit.exceeded,
+		V4Rejected: r.v4Limit.rejected,
+		V6Warnings: r.v6Limit.warnings,
+		V6Exceeded: r.v6Limit.exceeded,
+		V6Rejected: r.v6Limit.rejected,
+	}
+}
+
+// limitRejected applies the limits to an insert of route, reporting events,
+// and returns whether the insert must be rejected. The caller must hold the
+// lock of the route's address family.
+func (r *Rib) limitRejected(route Route) bool {
+	if r.limits == nil {
+		return false
+	}
+
+	n := r.nodeFor(route.Prefix.Masked())
+	newPrefix := n == nil || len(n.paths) == 0
+	newPath := n == nil || n.paths[route.PathID] == nil
+
+	st := &r.v4Limit
+	prefixes, paths := r.v4Count, r.v4PathCount
+	maxPrefixes, maxPaths := r.limits.MaxV4Prefixes, r.limits.MaxV4Paths
+	resPrefixes, resPaths := LimitV4Prefixes, LimitV4Paths
+	if route.Prefix.Addr().Is6() {
+		st = &r.v6Limit
+		prefixes, paths = r.v6Count, r.v6PathCount
+		maxPrefixes, maxPaths = r.limits.MaxV6Prefixes, r.limits.MaxV6Paths
+		resPrefixes, resPaths = LimitV6Prefixes, LimitV6Paths
+	}
+
+	// A prefix rejected by the prefix limit never adds a path, so the path
+	// limit is only checked once the prefix limit lets the insert through.
+	reject := newPrefix && r.checkLimit(st, 0, resPrefixes, prefixes, maxPrefixes, route)
+	if !reject && newPath {
+		reject = r.checkLimit(st, 1, resPaths, paths, maxPaths, route)
+	}
+	if reject {
+		st.rejected++
+	}
+	return reject
+}
+
+// checkLimit checks one counter about to grow from count to count+1.
+func (r *Rib) checkLimit(st *limitState, i int, res LimitResource, count, max int, route Route) bool {
+	if max <= 0 {
+		return false
+	}
+	if count >= max {
+		if !st.over[i] {
+			st.over[i] = true
+			st.exceeded++
+			r.limitEvent(LimitEvent{Kind: LimitExceeded, Resource: res, Count: count, Limit: max, Route: route})
+		}
+		return r.limits.Action&LimitReject != 0
+	}
+	st.over[i] = false
+	// Warn once, as the count first reaches the threshold rounded up.
+	if thr := warnThreshold(max, r.limits.WarnPercent); thr > 0 && count < thr && count+1 >= thr {
+		st.warnings++
+		r.limitEvent(LimitEvent{Kind: LimitWarning, Resource: res, Count: count + 1, Limit: max, Route: route})
+	}
+	return false
+}
+
+// warnThreshold returns the count at which percent of limit is reached,
+// rounded up and at least 1, or 0 when warnings are off.
+func warnThreshold(limit, percent int) int {
+	if percent <= 0 {
+		return 0
+	}
+	return max(1, (limit*percent+99)/100)
+}
+
+func (r *Rib) limitEvent(e LimitEvent) {
+	if r.limits.Action&LimitLog != 0 {
+		level := slog.LevelWarn
+		if e.Kind == LimitExceeded {
+			level = slog.LevelError
+		}
+		r.log().LogAttrs(context.Background(), level, "limit "+e.Kind.String(),
+			slog.String("resource", e.Resource.String()),
+			slog.Int("count", e.Count),
+			slog.Int("limit", e.Limit),
+			slog.String("prefix", e.Route.Prefix.String()),
+			slog.Uint64("path_id", uint64(e.Route.PathID)),
+		)
+	}
+	if r.limits.Action&LimitCallback != 0 && r.limits.OnLimit != nil {
+		r.limits.OnLimit(e)
+	}
+}
@@ -0,0 +1,141 @@
+package routing_table_test
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestLimitsReject(t *testing.T) {
+	router := rib.GetNewRib()
+	var events []rib.LimitEvent
+	router.SetLimits(&rib.Limits{
+		MaxV4Prefixes: 10,
+		MaxV6Paths:    2,
+		WarnPercent:   80,
+		Action:        rib.LimitReject | rib.LimitCallback,
+		OnLimit:       func(e rib.LimitEvent) { events = append(events, e) },
+	})
+
+	attr := &rib.RouteAttributes{AsPath: []uint32{64500}}
+	var batch []rib.Route
+	for i := 0; i < 8; i++ {
+		router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix(fmt.Sprintf("10.0.%d.0/24", i)), Attributes: attr})
+	}
+	for i := 8; i < 15; i++ {
+		batch = append(batch, rib.Route{Prefix: netip.MustParsePrefix(fmt.Sprintf("10.0.%d.0/24", i)), Attributes: attr})
+	}
+	added := router.InsertIPv4Batch(batch)
+	if router.V4Count() != 10 || len(added) != 2 {
+		t.Fatalf("expected 10 prefixes with 2 added by the batch, got %d and %v", router.V4Count(), added)
+	}
+
+	// Updates to existing paths are still accepted at the limit.
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.0.0.0/24"), Attributes: &rib.RouteAttributes{AsPath: []uint32{64501}}})
+	if got := router.LookupIPv4(netip.MustParsePrefix("10.0.0.0/24")); got.Attributes.AsPath[0] != 64501 {
+		t.Error("expected update at the limit to be accepted")
+	}
+
+	// Only paths are limited for IPv6.
+	v6 := netip.MustParsePrefix("2001:db8::/32")
+	for id := uint32(0); id < 3; id++ {
+		router.InsertIPv6(rib.Route{Prefix: v6, Attributes: attr, PathID: id})
+	}
+	if router.V6PathCount() != 2 {
+		t.Errorf("expected 2 IPv6 paths, got %d", router.V6PathCount())
+	}
+
+	want := []struct {
+		kind rib.LimitEventKind
+		res  rib.LimitResource
+	}{
+		{rib.LimitWarning, rib.LimitV4Prefixes},
+		{rib.LimitExceeded, rib.LimitV4Prefixes},
+		{rib.LimitWarning, rib.LimitV6Paths},
+		{rib.LimitExceeded, rib.LimitV6Paths},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %v", len(want), events)
+	}
+	for i, w := range want {
+		if events[i].Kind != w.kind || events[i].Resource != w.res {
+			t.Errorf("event %d: got %s, want %s %s", i, events[i], w.res, w.kind)
+		}
+	}
+
+	stats := router.LimitStats()
+	if stats != (rib.LimitStats{V4Warnings: 1, V4Exceeded: 1, V4Rejected: 5, V6Warnings: 1, V6Exceeded: 1, V6Rejected: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// Dropping below the limit re-arms the exceeded event.
+	router.DeleteIPv4(netip.MustParsePrefix("10.0.0.0/24"), 0)
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.0.20.0/24"), Attributes: attr})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.0.21.0/24"), Attributes: attr})
+	if got := router.LimitStats(); got.V4Exceeded != 2 || got.V4Rejected != 6 {
+		t.Errorf("unexpected stats after re-arm %+v", got)
+	}
+}
+
+func TestLimitsPrefixRejectSkipsPaths(t *testing.T) {
+	router := rib.GetNewRib()
+	var events []rib.LimitEvent
+	router.SetLimits(&rib.Limits{
+		MaxV4Prefixes: 2,
+		MaxV4Paths:    3,
+		WarnPercent:   100,
+		Action:        rib.LimitReject | rib.LimitCallback,
+		OnLimit:       func(e rib.LimitEvent) { events = append(events, e) },
+	})
+	for _, p := range []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24"} {
+		router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix(p)})
+	}
+
+	// The third prefix is refused by the prefix limit, so it must not count
+	// as the path that reaches the path limit.
+	if len(events) != 2 || events[0].Resource != rib.LimitV4Prefixes || events[1].Resource != rib.LimitV4Prefixes {
+		t.Errorf("expected only prefix limit events, got %v", events)
+	}
+	if got := router.LimitStats(); got != (rib.LimitStats{V4Warnings: 1, V4Exceeded: 1, V4Rejected: 1}) {
+		t.Errorf("unexpected stats %+v", got)
+	}
+}
+
+func TestLimitsWarnSmallLimit(t *testing.T) {
+	router := rib.GetNewRib()
+	var events []rib.LimitEvent
+	router.SetLimits(&rib.Limits{
+		MaxV4Prefixes: 10,
+		WarnPercent:   5,
+		Action:        rib.LimitCallback,
+		OnLimit:       func(e rib.LimitEvent) { events = append(events, e) },
+	})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.0.0.0/24")})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.0.1.0/24")})
+
+	// 5% of 10 rounds up to the first prefix.
+	if len(events) != 1 || events[0].Kind != rib.LimitWarning || events[0].Count != 1 {
+		t.Errorf("expected one warning at the first prefix, got %v", events)
+	}
+}
+
+func TestLimitsLogOnly(t *testing.T) {
+	router := rib.GetNewRib()
+	router.SetLimits(&rib.Limits{MaxV4Paths: 2})
+	for i := 0; i < 4; i++ {
+		router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix(fmt.Sprintf("10.%d.0.0/16", i))})
+	}
+	if router.V4PathCount() != 4 {
+		t.Errorf("expected inserts past the limit to be accepted, got %d paths", router.V4PathCount())
+	}
+	if got := router.LimitStats(); got.V4Exceeded != 1 || got.V4Rejected != 0 {
+		t.Errorf("unexpected stats %+v", got)
+	}
+
+	router.SetLimits(nil)
+	if got := router.LimitStats(); got != (rib.LimitStats{}) {
+		t.Errorf("expected counters reset, got %+v", got)
+	}
+}
@@ -0,0 +1,175 @@
+package routing_table
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// churnCounts counts the changes made to one address family.
+type churnCounts struct {
+	updates     uint64
+	withdrawals uint64
+}
+
+// ChurnStats counts paths stored (new or updated) and paths withdrawn since
+// the RIB was created or last reset. Rejected inserts are not counted; a
+// rejection that withdraws an accepted path counts as a withdrawal.
+type ChurnStats struct {
+	V4Updates     uint64
+	V4Withdrawals uint64
+	V6Updates     uint64
+	V6Withdrawals uint64
+}
+
+// Churn returns the update and withdrawal counters for both address families.
+func (r *Rib) Churn() ChurnStats {
+	r.v4mu.RLock()
+	r.v6mu.RLock()
+	defer r.v4mu.RUnlock()
+	defer r.v6mu.RUnlock()
+	return ChurnStats{
+		V4Updates:     r.v4Churn.updates,
+		V4Withdrawals: r.v4Churn.withdrawals,
+		V6Updates:     r.v6Churn.updates,
+		V6Withdrawals: r.v6Churn.withdrawals,
+	}
+}
+
+// SetLogger sets the logger used for statistics, route rejections and limit
+// events. Passing nil restores the default, slog.Default.
+//
+// Rejections of malformed routes (ErrBadMask, ErrOutsideGlobalUnicast,
+// ErrWrongFamily) are logged at warn level; routes rejected by the bogon
+// filter, import filter, import policy or limits at debug level, as these
+// are expected in normal operation.
+func (r *Rib) SetLogger(l *slog.Logger) {
+	r.v4mu.Lock()
+	r.v6mu.Lock()
+	defer r.v4mu.Unlock()
+	defer r.v6mu.Unlock()
+	r.logger = l
+}
+
+// log returns the logger. The caller must hold at least one family lock.
+func (r *Rib) log() *slog.Logger {
+	if r.logger != nil {
+		return r.logger
+	}
+	return slog.Default()
+}
+
+// logRejected logs a refused insert to l. err may be nil. Callers take l
+// from log under the family lock and log after releasing it, so a slow
+// handler does not hold up other inserts and lookups.
+func logRejected(l *slog.Logger, err error) {
+	var re *RouteError
+	if !errors.As(err, &re) {
+		return
+	}
+	level := slog.LevelDebug
+	if errors.Is(err, ErrBadMask) || errors.Is(err, ErrOutsideGlobalUnicast) || errors.Is(err, ErrWrongFamily) {
+		level = slog.LevelWarn
+	}
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	l.LogAttrs(ctx, level, "route rejected",
+		slog.String("op", re.Op),
+		slog.String("prefix", re.Prefix.String()),
+		slog.Uint64("path_id", uint64(re.PathID)),
+		slog.String("reason", re.Err.Error()),
+	)
+}
+
+// StartLogging spawns a background goroutine that logs the RIB statistics once per minute.
+// The goroutine stops when the provided context is canceled.
+func (r *Rib) StartLogging(ctx context.Context) {
+	r.StartLoggingInterval(ctx, time.Minute)
+}
+
+// StartLoggingInterval is StartLogging with a configurable interval. Each
+// record is logged at info level with the message "rib stats" and carries the
+// route and path counts, the prefix length distributions (ignoring zero
+// counts), the MemoryStats fields, and the update and withdrawal rates per
+// second over the last interval.
+func (r *Rib) StartLoggingInterval(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		last, prev := time.Now(), r.Churn()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				churn := r.Churn()
+				r.logStats(ctx, churn, prev, now.Sub(last))
+				last, prev = now, churn
+			}
+		}
+	}()
+}
+
+// logStats logs one statistics record. churn and prev are the churn counters
+// at the end and start of the elapsed interval.
+func (r *Rib) logStats(ctx context.Context, churn, prev ChurnStats, elapsed time.Duration) {
+	r.v4mu.RLock()
+	l := r.log()
+	v4Count, v4Paths, v4Masks := r.v4Count, r.v4PathCount, maskAttrs(r.v4masks)
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	v6Count, v6Paths, v6Masks := r.v6Count, r.v6PathCount, maskAttrs(r.v6masks)
+	r.v6mu.RUnlock()
+
+	mem := r.MemoryUsage()
+	rate := func(cur, old uint64) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return float64(cur-old) / elapsed.Seconds()
+	}
+
+	l.LogAttrs(ctx, slog.LevelInfo, "rib stats",
+		slog.Int("v4_routes", v4Count),
+		slog.Int("v6_routes", v6Count),
+		slog.Int("v4_paths", v4Paths),
+		slog.Int("v6_paths", v6Paths),
+		slog.Group("v4_masks", v4Masks...),
+		slog.Group("v6_masks", v6Masks...),
+		slog.Group("memory",
+			slog.Uint64("routing_tables_effective", mem.RoutingTablesEffective),
+			slog.Uint64("routing_tables_overhead", mem.RoutingTablesOverhead),
+			slog.Uint64("route_attributes_effective", mem.RouteAttributesEffective),
+			slog.Uint64("route_attributes_overhead", mem.RouteAttributesOverhead),
+		),
+		slog.Group("churn",
+			slog.Float64("v4_updates_per_sec", rate(churn.V4Updates, prev.V4Updates)),
+			slog.Float64("v4_withdrawals_per_sec", rate(churn.V4Withdrawals, prev.V4Withdrawals)),
+			slog.Float64("v6_updates_per_sec", rate(churn.V6Updates, prev.V6Updates)),
+			slog.Float64("v6_withdrawals_per_sec", rate(churn.V6Withdrawals, prev.V6Withdrawals)),
+		),
+	)
+}
+
+// maskAttrs returns the non-zero counts of a mask distribution keyed by
+// prefix length, in ascending order.
+func maskAttrs(masks map[int]int) []any {
+	lengths := make([]int, 0, len(masks))
+	for mask, n := range masks {
+		if n > 0 {
+			lengths = append(lengths, mask)
+		}
+	}
+	slices.Sort(lengths)
+	attrs := make([]any, len(lengths))
+	for i, mask := range lengths {
+		attrs[i] = slog.Int(strconv.Itoa(mask), masks[mask])
+	}
+	return attrs
+}
@@ -0,0 +1,183 @@
+package routing_table_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+// syncBuffer is a bytes.Buffer safe for the logging goroutine to write to.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records decodes the JSON log records written so far.
+func (b *syncBuffer) records(t *testing.T) []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var recs []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("bad log line %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestLoggerRejections(t *testing.T) {
+	var buf syncBuffer
+	router := rib.GetNewRib()
+	router.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	router.SetImportFilter(rib.MustParseFilter("not aspath contains 3356"))
+	router.SetLimits(&rib.Limits{MaxV4Prefixes: 1, Action: rib.LimitLog | rib.LimitReject})
+
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24")})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/25")})
+	router.InsertIPv6Batch([]rib.Route{{Prefix: netip.MustParsePrefix("2001:db8::/32"), Attributes: &rib.RouteAttributes{AsPath: []uint32{3356}}}})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("8.8.8.0/24")})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32")})
+	router.InsertIPv6BatchResults([]rib.Route{{Prefix: netip.MustParsePrefix("1.1.1.0/24")}})
+
+	want := []struct {
+		level, msg, reason string
+	}{
+		{"WARN", "route rejected", "mask outside allowed range"},
+		{"DEBUG", "route rejected", "rejected by import filter"},
+		{"ERROR", "limit exceeded", ""},
+		{"DEBUG", "route rejected", "limit exceeded"},
+		{"WARN", "route rejected", "wrong address family"},
+		{"WARN", "route rejected", "wrong address family"},
+	}
+	recs := buf.records(t)
+	if len(recs) != len(want) {
+		t.Fatalf("expected %d records, got %v", len(want), recs)
+	}
+	for i, w := range want {
+		rec := recs[i]
+		if rec["level"] != w.level || rec["msg"] != w.msg {
+			t.Errorf("record %d: got %v %v, want %s %s", i, rec["level"], rec["msg"], w.level, w.msg)
+		}
+		if w.reason != "" && rec["reason"] != w.reason {
+			t.Errorf("record %d: got reason %v, want %s", i, rec["reason"], w.reason)
+		}
+	}
+	if recs[2]["resource"] != "IPv4 prefixes" || recs[2]["prefix"] != "8.8.8.0/24" {
+		t.Errorf("unexpected limit record %v", recs[2])
+	}
+}
+
+// blockingWriter holds every write until release is closed.
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+	return len(p), nil
+}
+
+func TestLoggerOutsideLock(t *testing.T) {
+	w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	router := rib.GetNewRib()
+	router.SetLogger(slog.New(slog.NewTextHandler(w, nil)))
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24")})
+
+	done := make(chan error)
+	go func() { done <- router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/25")}) }()
+	<-w.started
+
+	// The rejection is being logged, but the RIB is not locked meanwhile.
+	lookup := make(chan bool)
+	go func() { lookup <- router.LookupIPv4(netip.MustParsePrefix("1.1.1.0/24")) != nil }()
+	select {
+	case found := <-lookup:
+		if !found {
+			t.Error("expected lookup to find 1.1.1.0/24")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lookup blocked by a slow log handler")
+	}
+	router.SetLogger(nil)
+	close(w.release)
+	if err := <-done; !errors.Is(err, rib.ErrBadMask) {
+		t.Errorf("expected ErrBadMask, got %v", err)
+	}
+}
+
+func TestChurnAndStatsLogging(t *testing.T) {
+	var buf syncBuffer
+	router := rib.GetNewRib()
+	router.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24")})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24"), PathID: 1})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.0.0.0/16")})
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32")})
+	router.DeleteIPv4(netip.MustParsePrefix("1.1.1.0/24"), 1)
+	router.DeleteIPv4(netip.MustParsePrefix("1.1.1.0/24"), 1)
+
+	want := rib.ChurnStats{V4Updates: 3, V4Withdrawals: 1, V6Updates: 1}
+	if got := router.Churn(); got != want {
+		t.Errorf("got churn %+v, want %+v", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	router.StartLoggingInterval(ctx, 10*time.Millisecond)
+
+	var rec map[string]any
+	for deadline := time.Now().Add(5 * time.Second); rec == nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		for _, r := range buf.records(t) {
+			if r["msg"] == "rib stats" {
+				rec = r
+			}
+		}
+	}
+	cancel()
+	if rec == nil {
+		t.Fatal("no stats record logged")
+	}
+	if rec["v4_routes"] != 2.0 || rec["v6_routes"] != 1.0 || rec["v4_paths"] != 2.0 {
+		t.Errorf("unexpected counts in %v", rec)
+	}
+	masks, _ := rec["v4_masks"].(map[string]any)
+	if masks["16"] != 1.0 || masks["24"] != 1.0 || len(masks) != 2 {
+		t.Errorf("unexpected v4 masks %v", rec["v4_masks"])
+	}
+	if mem, _ := rec["memory"].(map[string]any); mem["routing_tables_overhead"] != 2304.0 {
+		t.Errorf("unexpected memory %v", rec["memory"])
+	}
+	if churn, _ := rec["churn"].(map[string]any); len(churn) != 4 {
+		t.Errorf("unexpected churn %v", rec["churn"])
+	}
+
+	router.Reset()
+	if got := router.Churn(); got != (rib.ChurnStats{}) {
+		t.Errorf("expected churn cleared by Reset, got %+v", got)
+	}
+}
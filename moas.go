@@ -0,0 +1,250 @@
+package routing_table
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// ConflictKind classifies an OriginConflict.
+type ConflictKind uint8
+
+const (
+	// ConflictMOAS is a prefix whose paths carry more than one origin ASN.
+	ConflictMOAS ConflictKind = iota
+	// ConflictSubPrefix is a more-specific announced by an origin that does
+	// not originate its covering prefix.
+	ConflictSubPrefix
+)
+
+func (k ConflictKind) String() string {
+	switch k {
+	case ConflictMOAS:
+		return "moas"
+	case ConflictSubPrefix:
+		return "sub-prefix"
+	}
+	return fmt.Sprintf("ConflictKind(%d)", uint8(k))
+}
+
+// OriginConflict describes a prefix whose origin ASNs disagree, either among
+// its own Add-Path paths or with its covering prefix. The origin of a path is
+// the last ASN of its AS path; paths with an empty AS path have no origin and
+// never take part in a conflict.
+type OriginConflict struct {
+	Kind   ConflictKind
+	Prefix netip.Prefix
+	// Covering is the nearest less-specific prefix holding paths. It is only
+	// set for ConflictSubPrefix.
+	Covering netip.Prefix
+	// Routes holds every path involved, ordered by PathID: the covering
+	// prefix's paths first for ConflictSubPrefix, then those of Prefix.
+	Routes []Route
+}
+
+func (c OriginConflict) String() string {
+	if c.Kind == ConflictSubPrefix {
+		return fmt.Sprintf("%s %s under %s", c.Kind, c.Prefix, c.Covering)
+	}
+	return fmt.Sprintf("%s %s", c.Kind, c.Prefix)
+}
+
+// OriginConflicts scans the RIB and returns every MOAS and sub-prefix
+// conflict, IPv4 before IPv6, in address order. For each prefix a MOAS
+// conflict is listed before a conflict with its covering prefix.
+func (r *Rib) OriginConflicts() []OriginConflict {
+	var conflicts []OriginConflict
+
+	r.v4mu.RLock()
+	r.walkIPv4(scanConflicts(&conflicts))
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(scanConflicts(&conflicts))
+	r.v6mu.RUnlock()
+
+	return conflicts
+}
+
+// scanConflicts returns a walk callback that appends conflicts to out. The
+// walk visits prefixes in address order, so a stack of routed prefixes
+// seen so far yields the nearest covering prefix of each one.
+func scanConflicts(out *[]OriginConflict) func(netip.Prefix, *node) {
+	type routed struct {
+		prefix  netip.Prefix
+		n       *node
+		origins []uint32
+	}
+	var stack []routed
+
+	return func(prefix netip.Prefix, n *node) {
+		origins := nodeOrigins(n)
+		if len(origins) > 1 {
+			*out = append(*out, OriginConflict{Kind: ConflictMOAS, Prefix: prefix, Routes: sortedRoutes(n, prefix)})
+		}
+
+		for len(stack) > 0 && !stack[len(stack)-1].prefix.Contains(prefix.Addr()) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			cov := stack[len(stack)-1]
+			if originsConflict(cov.origins, origins) {
+				*out = append(*out, subPrefixConflict(cov.prefix, cov.n, prefix, n))
+			}
+		}
+		stack = append(stack, routed{prefix, n, origins})
+	}
+}
+
+// SetConflictHook installs fn to be called for every origin conflict an
+// insert creates. A path only creates conflicts when it brings an origin the
+// prefix did not already have: it can turn the prefix into a MOAS, conflict
+// with the covering prefix, or, when it is the prefix's only path, conflict
+// with the nearest routed more-specifics. Passing nil removes the hook.
+//
+// fn runs with the write lock of the route's address family held, so it must
+// not call back into the RIB.
+func (r *Rib) SetConflictHook(fn func(OriginConflict)) {
+	r.v4mu.Lock()
+	r.v6mu.Lock()
+	defer r.v4mu.Unlock()
+	defer r.v6mu.Unlock()
+	r.conflictHook = fn
+}
+
+// flagConflicts reports the conflicts created by storing pathID on n, which
+// replaced oldAttr (nil for a new path). The caller must hold the lock of the
+// prefix's address family.
+func (r *Rib) flagConflicts(prefix netip.Prefix, n *node, pathID uint32, oldAttr *RouteAttributes) {
+	origin, ok := pathOrigin(n.paths[pathID])
+	if !ok {
+		return
+	}
+	if old, ok := pathOrigin(oldAttr); ok && old == origin {
+		return
+	}
+
+	others := false
+	for id, attr := range n.paths {
+		if id == pathID {
+			continue
+		}
+		if o, ok := pathOrigin(attr); ok {
+			if o == origin {
+				return
+			}
+			others = true
+		}
+	}
+	if others {
+		r.conflictHook(OriginConflict{Kind: ConflictMOAS, Prefix: prefix, Routes: sortedRoutes(n, prefix)})
+	}
+
+	depth := prefix.Bits()
+	for a := n.parent; a != nil; a = a.parent {
+		depth--
+		if len(a.paths) == 0 {
+			continue
+		}
+		covOrigins := nodeOrigins(a)
+		if len(covOrigins) > 0 && !slices.Contains(covOrigins, origin) {
+			cov := netip.PrefixFrom(prefix.Addr(), depth).Masked()
+			r.conflictHook(subPrefixConflict(cov, a, prefix, n))
+		}
+		break
+	}
+
+	// Only a prefix's sole path can make it newly conflict with the
+	// more-specifics it covers.
+	if len(n.paths) != 1 {
+		return
+	}
+	maxBits := 24
+	if prefix.Addr().Is6() {
+		maxBits = 48
+	}
+	origins := []uint32{origin}
+	routedBelow(n, prefix, maxBits, func(p netip.Prefix, d *node) {
+		if originsConflict(origins, nodeOrigins(d)) {
+			r.conflictHook(subPrefixConflict(prefix, n, p, d))
+		}
+	})
+}
+
+// routedBelow calls fn for the nearest prefixes holding paths in each
+// subtree under n, whose prefix is p, without descending below them.
+func routedBelow(n *node, p netip.Prefix, maxBits int, fn func(netip.Prefix, *node)) {
+	if p.Bits() >= maxBits {
+		return
+	}
+	lo := netip.PrefixFrom(p.Addr(), p.Bits()+1)
+	for bit, child := range n.children {
+		if child == nil {
+			continue
+		}
+		cp := lo
+		if bit == 1 {
+			cp = siblingPrefix(lo)
+		}
+		if len(child.paths) > 0 {
+			fn(cp, child)
+			continue
+		}
+		routedBelow(child, cp, maxBits, fn)
+	}
+}
+
+func subPrefixConflict(cov netip.Prefix, cn *node, prefix netip.Prefix, n *node) OriginConflict {
+	return OriginConflict{
+		Kind:     ConflictSubPrefix,
+		Prefix:   prefix,
+		Covering: cov,
+		Routes:   append(sortedRoutes(cn, cov), sortedRoutes(n, prefix)...),
+	}
+}
+
+// originsConflict reports whether a more-specific with the given origins
+// conflicts with a covering prefix with origins cov: it does when it has an
+// origin the covering prefix lacks. Either side having no origin at all is
+// never a conflict.
+func originsConflict(cov, origins []uint32) bool {
+	if len(cov) == 0 {
+		return false
+	}
+	for _, o := range origins {
+		if !slices.Contains(cov, o) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathOrigin returns the origin ASN of attr, if it has a non-empty AS path.
+func pathOrigin(attr *RouteAttributes) (uint32, bool) {
+	if attr == nil || len(attr.AsPath) == 0 {
+		return 0, false
+	}
+	return attr.AsPath[len(attr.AsPath)-1], true
+}
+
+// nodeOrigins returns the distinct origin ASNs of n's paths in ascending order.
+func nodeOrigins(n *node) []uint32 {
+	var origins []uint32
+	for _, attr := range n.paths {
+		if o, ok := pathOrigin(attr); ok && !slices.Contains(origins, o) {
+			origins = append(origins, o)
+		}
+	}
+	slices.Sort(origins)
+	return origins
+}
+
+// sortedRoutes returns n's paths as Routes ordered by PathID.
+func sortedRoutes(n *node, prefix netip.Prefix) []Route {
+	routes := nodeToRoutes(n, prefix)
+	slices.SortFunc(routes, func(a, b Route) int {
+		return cmp.Compare(a.PathID, b.PathID)
+	})
+	return routes
+}
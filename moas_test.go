@@ -0,0 +1,96 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func originPath(asns ...uint32) *rib.RouteAttributes {
+	return &rib.RouteAttributes{AsPath: asns}
+}
+
+func TestOriginConflicts(t *testing.T) {
+	router := rib.GetNewRib()
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Attributes: originPath(3356, 64500)})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.0.0.0/8"), Attributes: originPath(174, 64500), PathID: 1})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.1.0.0/16"), Attributes: originPath(174, 64500)})  // same origin
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.2.0.0/16"), Attributes: originPath(174, 64666)})  // hijack
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("10.2.1.0/24"), Attributes: originPath(3356, 64666)}) // same as /16
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("20.0.0.0/16"), Attributes: originPath(64501)})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("20.0.0.0/16"), Attributes: originPath(64502), PathID: 2}) // MOAS
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("20.0.0.0/16"), Attributes: &rib.RouteAttributes{}, PathID: 3})
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32"), Attributes: originPath(64500)})
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8:1::/48"), Attributes: originPath(64501)})
+
+	want := []struct {
+		kind     rib.ConflictKind
+		prefix   string
+		covering string
+		routes   int
+	}{
+		{rib.ConflictSubPrefix, "10.2.0.0/16", "10.0.0.0/8", 3},
+		{rib.ConflictMOAS, "20.0.0.0/16", "", 3},
+		{rib.ConflictSubPrefix, "2001:db8:1::/48", "2001:db8::/32", 2},
+	}
+	got := router.OriginConflicts()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d conflicts, got %v", len(want), got)
+	}
+	for i, w := range want {
+		c := got[i]
+		var cov netip.Prefix
+		if w.covering != "" {
+			cov = netip.MustParsePrefix(w.covering)
+		}
+		if c.Kind != w.kind || c.Prefix != netip.MustParsePrefix(w.prefix) || c.Covering != cov || len(c.Routes) != w.routes {
+			t.Errorf("conflict %d: got %s with %d routes, want %s %s under %q with %d routes",
+				i, c, len(c.Routes), w.kind, w.prefix, w.covering, w.routes)
+		}
+	}
+	if r := got[0].Routes; r[0].Prefix.Bits() != 8 || r[2].Prefix.Bits() != 16 {
+		t.Errorf("expected covering routes first, got %v", r)
+	}
+}
+
+func TestConflictHook(t *testing.T) {
+	router := rib.GetNewRib()
+	var flagged []rib.OriginConflict
+	router.SetConflictHook(func(c rib.OriginConflict) {
+		flagged = append(flagged, c)
+	})
+
+	insert := func(prefix string, pathID uint32, asns ...uint32) []rib.OriginConflict {
+		flagged = nil
+		router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix(prefix), Attributes: originPath(asns...), PathID: pathID})
+		return flagged
+	}
+
+	if got := insert("10.1.0.0/16", 0, 64500); len(got) != 0 {
+		t.Errorf("first route flagged %v", got)
+	}
+	if got := insert("10.1.1.0/24", 0, 174, 64500); len(got) != 0 {
+		t.Errorf("same-origin more-specific flagged %v", got)
+	}
+	if got := insert("10.1.2.0/24", 0, 64666); len(got) != 1 || got[0].Kind != rib.ConflictSubPrefix {
+		t.Errorf("expected sub-prefix conflict, got %v", got)
+	}
+	if got := insert("10.1.0.0/16", 1, 64501); len(got) != 1 || got[0].Kind != rib.ConflictMOAS {
+		t.Errorf("expected MOAS conflict, got %v", got)
+	}
+	if got := insert("10.1.0.0/16", 1, 3356, 64501); len(got) != 0 {
+		t.Errorf("re-announcement with the same origin flagged %v", got)
+	}
+
+	// A new covering prefix conflicts with the more-specifics already present.
+	got := insert("10.0.0.0/8", 0, 64999)
+	if len(got) != 1 || got[0].Prefix != netip.MustParsePrefix("10.1.0.0/16") || got[0].Covering != netip.MustParsePrefix("10.0.0.0/8") {
+		t.Errorf("expected 10.1.0.0/16 flagged under 10.0.0.0/8, got %v", got)
+	}
+
+	router.SetConflictHook(nil)
+	if got := insert("10.1.3.0/24", 0, 1); len(got) != 0 {
+		t.Errorf("removed hook still called: %v", got)
+	}
+}
@@ -0,0 +1,341 @@
+package routing_table
+
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+// Peer identifies a BGP neighbor. Address is the session address and keys
+// the peer within a PeerRib.
+type Peer struct {
+	ASN      uint32
+	Address  netip.Addr
+	RouterID netip.Addr
+}
+
+// PeerRoute is a path tagged with the peer it was learned from. Route.PathID
+// is the PathID the peer sent.
+type PeerRoute struct {
+	Peer  Peer
+	Route Route
+}
+
+// peerPath identifies a path by the peer address and the PathID it sent.
+type peerPath struct {
+	addr   netip.Addr
+	pathID uint32
+}
+
+type peerState struct {
+	peer  Peer
+	adjIn *Rib
+}
+
+// PeerRib keeps an Adj-RIB-In per peer and a combined Loc-RIB holding the
+// paths of every peer, so best path selection spans peers. Each (peer,
+// PathID) pair is stored in the Loc-RIB under a synthetic PathID; when paths
+// are otherwise equal, the one from the peer whose PathID was assigned first
+// wins. All tables share one attribute table, so attributes received from
+// several peers are stored once.
+//
+// Routes must be inserted and deleted through the PeerRib so that the
+// Adj-RIBs-In and the Loc-RIB stay in sync. Writes are serialized with each
+// other and with Best and AllPaths.
+type PeerRib struct {
+	mu    sync.RWMutex
+	peers map[netip.Addr]*peerState
+	loc   *Rib
+	attrs *attrTable
+
+	// ids maps paths to their Loc-RIB PathID and paths maps them back.
+	ids    map[peerPath]uint32
+	paths  map[uint32]peerPath
+	nextID uint32
+}
+
+// NewPeerRib returns a PeerRib with no peers.
+func NewPeerRib() *PeerRib {
+	loc := GetNewRib()
+	loc.peerOwned = true
+	return &PeerRib{
+		peers: make(map[netip.Addr]*peerState),
+		loc:   &loc,
+		attrs: loc.attrTable,
+		ids:   make(map[peerPath]uint32),
+		paths: make(map[uint32]peerPath),
+	}
+}
+
+// AddPeer registers a peer with an empty Adj-RIB-In, or updates the ASN and
+// router ID of a registered one.
+func (p *PeerRib) AddPeer(peer Peer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if st, ok := p.peers[peer.Address]; ok {
+		st.peer = peer
+		return
+	}
+	adjIn := GetNewRib()
+	adjIn.attrTable = p.attrs
+	adjIn.peerOwned = true
+	p.peers[peer.Address] = &peerState{peer: peer, adjIn: &adjIn}
+}
+
+// Peers returns the registered peers ordered by address.
+func (p *PeerRib) Peers() []Peer {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	peers := make([]Peer, 0, len(p.peers))
+	for _, st := range p.peers {
+		peers = append(peers, st.peer)
+	}
+	slices.SortFunc(peers, func(a, b Peer) int { return a.Address.Compare(b.Address) })
+	return peers
+}
+
+// AdjRibIn returns the Adj-RIB-In of a peer, and whether the peer is
+// registered. It may be queried, and configured with an import filter,
+// policy, bogon filter or limits that apply to that peer only, but routes
+// must be inserted and deleted through the PeerRib. Reset and Replace panic
+// on it, as they would discard the attribute table shared by all peers.
+func (p *PeerRib) AdjRibIn(addr netip.Addr) (*Rib, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	st, ok := p.peers[addr]
+	if !ok {
+		return nil, false
+	}
+	return st.adjIn, true
+}
+
+// LocRib returns the combined table for lookups. Its paths carry synthetic
+// PathIDs; use Best or AllPaths to see which peer they came from. It must not
+// be modified, and Reset and Replace panic on it.
+func (p *PeerRib) LocRib() *Rib {
+	return p.loc
+}
+
+// Insert adds or updates a route received from the peer at addr, in the
+// peer's Adj-RIB-In and then in the Loc-RIB with the attributes stored after
+// the peer's import policy. Errors are those of InsertIPv4 and InsertIPv6,
+// or ErrUnknownPeer. An update rejected by the peer's import policy also
+// withdraws the path from the Loc-RIB.
+func (p *PeerRib) Insert(addr netip.Addr, route Route) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st, ok := p.peers[addr]
+	if !ok {
+		return insertError(route, ErrUnknownPeer)
+	}
+
+	var err error
+	if route.Prefix.Addr().Is4() {
+		err = st.adjIn.InsertIPv4(route)
+	} else {
+		err = st.adjIn.InsertIPv6(route)
+	}
+	p.syncLoc(addr, st.adjIn, route.Prefix.Masked(), route.PathID)
+	return err
+}
+
+// Delete withdraws a path received from the peer at addr from its
+// Adj-RIB-In and the Loc-RIB. Errors are those of DeleteIPv4 and DeleteIPv6,
+// or ErrUnknownPeer.
+func (p *PeerRib) Delete(addr netip.Addr, prefix netip.Prefix, pathID uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st, ok := p.peers[addr]
+	if !ok {
+		return deleteError(prefix, pathID, ErrUnknownPeer)
+	}
+
+	var err error
+	if prefix.Addr().Is4() {
+		err = st.adjIn.DeleteIPv4(prefix, pathID)
+	} else {
+		err = st.adjIn.DeleteIPv6(prefix, pathID)
+	}
+	p.syncLoc(addr, st.adjIn, prefix.Masked(), pathID)
+	return err
+}
+
+// syncLoc makes the Loc-RIB path for a peer's prefix and PathID match its
+// Adj-RIB-In. The caller must hold p.mu.
+func (p *PeerRib) syncLoc(addr netip.Addr, adjIn *Rib, prefix netip.Prefix, pathID uint32) {
+	if !prefix.IsValid() {
+		return
+	}
+	key := peerPath{addr, pathID}
+	attr, ok := adjIn.path(prefix, pathID)
+	if !ok {
+		if id, known := p.ids[key]; known {
+			if prefix.Addr().Is4() {
+				p.loc.DeleteIPv4(prefix, id)
+			} else {
+				p.loc.DeleteIPv6(prefix, id)
+			}
+		}
+		return
+	}
+
+	id, known := p.ids[key]
+	if !known {
+		id = p.nextID
+		p.nextID++
+		p.ids[key] = id
+		p.paths[id] = key
+	}
+	locRoute := Route{Prefix: prefix, Attributes: attr, PathID: id}
+	if prefix.Addr().Is4() {
+		p.loc.InsertIPv4(locRoute)
+	} else {
+		p.loc.InsertIPv6(locRoute)
+	}
+}
+
+// path returns the attributes of a prefix and PathID, and whether the path
+// exists.
+func (r *Rib) path(prefix netip.Prefix, pathID uint32) (*RouteAttributes, bool) {
+	mu := r.v4mu
+	if prefix.Addr().Is6() {
+		mu = r.v6mu
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	n := r.nodeFor(prefix.Masked())
+	if n == nil {
+		return nil, false
+	}
+	attr, ok := n.paths[pathID]
+	return attr, ok
+}
+
+// PeerDown removes every path of the peer at addr from its Adj-RIB-In and
+// the Loc-RIB, as when its session goes down, and returns the number of
+// paths removed. The peer stays registered, with its configuration.
+func (p *PeerRib) PeerDown(addr netip.Addr) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st, ok := p.peers[addr]
+	if !ok {
+		return 0, ErrUnknownPeer
+	}
+
+	var adj4, adj6, loc4, loc6 []PrefixWithID
+	collect := func(adj, loc *[]PrefixWithID) func(netip.Prefix, *node) {
+		return func(prefix netip.Prefix, n *node) {
+			for id := range n.paths {
+				*adj = append(*adj, PrefixWithID{prefix, id})
+				if locID, known := p.ids[peerPath{addr, id}]; known {
+					*loc = append(*loc, PrefixWithID{prefix, locID})
+				}
+			}
+		}
+	}
+	st.adjIn.v4mu.RLock()
+	st.adjIn.walkIPv4(collect(&adj4, &loc4))
+	st.adjIn.v4mu.RUnlock()
+	st.adjIn.v6mu.RLock()
+	st.adjIn.walkIPv6(collect(&adj6, &loc6))
+	st.adjIn.v6mu.RUnlock()
+
+	p.loc.DeleteIPv4Batch(loc4)
+	p.loc.DeleteIPv6Batch(loc6)
+	st.adjIn.DeleteIPv4Batch(adj4)
+	st.adjIn.DeleteIPv6Batch(adj6)
+
+	for key, id := range p.ids {
+		if key.addr == addr {
+			delete(p.ids, key)
+			delete(p.paths, id)
+		}
+	}
+	return len(adj4) + len(adj6), nil
+}
+
+// RoutesFromPeer returns every path in the Adj-RIB-In of the peer at addr,
+// IPv4 before IPv6 in address order and by PathID within a prefix.
+func (p *PeerRib) RoutesFromPeer(addr netip.Addr) (v4 []Route, v6 []Route, err error) {
+	adjIn, ok := p.AdjRibIn(addr)
+	if !ok {
+		return nil, nil, ErrUnknownPeer
+	}
+	adjIn.v4mu.RLock()
+	adjIn.walkIPv4(func(prefix netip.Prefix, n *node) {
+		v4 = append(v4, sortedRoutes(n, prefix)...)
+	})
+	adjIn.v4mu.RUnlock()
+
+	adjIn.v6mu.RLock()
+	adjIn.walkIPv6(func(prefix netip.Prefix, n *node) {
+		v6 = append(v6, sortedRoutes(n, prefix)...)
+	})
+	adjIn.v6mu.RUnlock()
+	return v4, v6, nil
+}
+
+// Best returns the best path for an exact prefix across all peers, and
+// whether there is one.
+func (p *PeerRib) Best(prefix netip.Prefix) (PeerRoute, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var best PeerRoute
+	found := false
+	p.loc.locked(prefix, func(n *node) {
+		attr := n.bestPath()
+		if attr == nil {
+			return
+		}
+		best, found = p.peerRoute(n.route(prefix.Masked(), bestPathID(n, attr)))
+	})
+	return best, found
+}
+
+// AllPaths returns every path for an exact prefix across all peers, ordered
+// by peer address and PathID.
+func (p *PeerRib) AllPaths(prefix netip.Prefix) []PeerRoute {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var routes []PeerRoute
+	p.loc.locked(prefix, func(n *node) {
+		for id := range n.paths {
+			if pr, ok := p.peerRoute(n.route(prefix.Masked(), id)); ok {
+				routes = append(routes, pr)
+			}
+		}
+	})
+	slices.SortFunc(routes, func(a, b PeerRoute) int {
+		if c := a.Peer.Address.Compare(b.Peer.Address); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Route.PathID, b.Route.PathID)
+	})
+	return routes
+}
+
+// peerRoute translates a Loc-RIB route back to its peer and PathID. The
+// caller must hold p.mu.
+func (p *PeerRib) peerRoute(rt Route) (PeerRoute, bool) {
+	key, ok := p.paths[rt.PathID]
+	if !ok {
+		return PeerRoute{}, false
+	}
+	rt.PathID = key.pathID
+	return PeerRoute{Peer: p.peers[key.addr].peer, Route: rt}, true
+}
+
+// locked calls fn with the node holding prefix, if any, under the read lock
+// of its address family.
+func (r *Rib) locked(prefix netip.Prefix, fn func(n *node)) {
+	mu := r.v4mu
+	if prefix.Addr().Is6() {
+		mu = r.v6mu
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if n := r.nodeFor(prefix.Masked()); n != nil {
+		fn(n)
+	}
+}
@@ -0,0 +1,138 @@
+package routing_table_test
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestPeerRib(t *testing.T) {
+	pr := rib.NewPeerRib()
+	a := rib.Peer{ASN: 174, Address: netip.MustParseAddr("192.0.2.1"), RouterID: netip.MustParseAddr("10.0.0.1")}
+	b := rib.Peer{ASN: 3356, Address: netip.MustParseAddr("2001:db8::2"), RouterID: netip.MustParseAddr("10.0.0.2")}
+	pr.AddPeer(b)
+	pr.AddPeer(a)
+
+	if peers := pr.Peers(); len(peers) != 2 || peers[0] != a || peers[1] != b {
+		t.Fatalf("unexpected peers %v", peers)
+	}
+
+	p4 := netip.MustParsePrefix("1.1.1.0/24")
+	p6 := netip.MustParsePrefix("2606:4700::/32")
+	viaA := &rib.RouteAttributes{AsPath: []uint32{174, 3356, 13335}}
+	viaB := &rib.RouteAttributes{AsPath: []uint32{3356, 13335}}
+
+	pr.Insert(a.Address, rib.Route{Prefix: p4, Attributes: viaA})
+	pr.Insert(a.Address, rib.Route{Prefix: p4, Attributes: viaA, PathID: 7})
+	pr.Insert(b.Address, rib.Route{Prefix: p4, Attributes: viaB})
+	pr.Insert(b.Address, rib.Route{Prefix: p6, Attributes: viaB})
+
+	best, ok := pr.Best(p4)
+	if !ok || best.Peer != b || best.Route.PathID != 0 || best.Route.Attributes.AsPath[0] != 3356 {
+		t.Errorf("expected shorter path from peer b, got %+v", best)
+	}
+	paths := pr.AllPaths(p4)
+	if len(paths) != 3 || paths[0].Peer != a || paths[0].Route.PathID != 0 || paths[1].Route.PathID != 7 || paths[2].Peer != b {
+		t.Errorf("unexpected paths %+v", paths)
+	}
+	if got := pr.LocRib().V4PathCount(); got != 3 {
+		t.Errorf("expected 3 Loc-RIB paths, got %d", got)
+	}
+
+	v4, v6, err := pr.RoutesFromPeer(b.Address)
+	if err != nil || len(v4) != 1 || len(v6) != 1 {
+		t.Errorf("unexpected routes from peer b: %v %v %v", v4, v6, err)
+	}
+
+	// A per-peer import policy applies before the Loc-RIB sees the route,
+	// and a rejected update withdraws the path there too.
+	adjIn, _ := pr.AdjRibIn(a.Address)
+	adjIn.SetImportPolicy(&rib.Policy{Terms: []rib.PolicyTerm{
+		{Match: rib.MustParseFilter("aspath contains 64666"), Result: rib.PolicyReject},
+		{Actions: []rib.PolicyAction{rib.SetLocalPref(200)}},
+	}})
+	pr.Insert(a.Address, rib.Route{Prefix: p4, Attributes: viaA})
+	if best, _ := pr.Best(p4); best.Peer != a || best.Route.Attributes.LocalPref != 200 {
+		t.Errorf("expected peer a preferred after policy, got %+v", best)
+	}
+	err = pr.Insert(a.Address, rib.Route{Prefix: p4, Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 64666}}, PathID: 7})
+	if !errors.Is(err, rib.ErrPolicyRejected) || len(pr.AllPaths(p4)) != 2 {
+		t.Errorf("expected rejected update to withdraw path 7, got %v and %d paths", err, len(pr.AllPaths(p4)))
+	}
+
+	if err := pr.Delete(b.Address, p4, 0); err != nil || len(pr.AllPaths(p4)) != 1 {
+		t.Errorf("delete: %v, %d paths left", err, len(pr.AllPaths(p4)))
+	}
+	pr.Insert(b.Address, rib.Route{Prefix: p4, Attributes: viaB})
+
+	n, err := pr.PeerDown(b.Address)
+	if err != nil || n != 2 {
+		t.Errorf("expected 2 paths removed, got %d %v", n, err)
+	}
+	if _, ok := pr.Best(p6); ok || pr.LocRib().V6Count() != 0 {
+		t.Error("expected peer b's IPv6 route gone")
+	}
+	if best, ok := pr.Best(p4); !ok || best.Peer != a {
+		t.Errorf("expected only peer a left, got %+v", best)
+	}
+	if v4, v6, _ := pr.RoutesFromPeer(b.Address); len(v4)+len(v6) != 0 {
+		t.Errorf("expected empty Adj-RIB-In, got %v %v", v4, v6)
+	}
+
+	// The peer stays registered and can come back.
+	if err := pr.Insert(b.Address, rib.Route{Prefix: p6, Attributes: viaB}); err != nil {
+		t.Errorf("insert after peer down: %v", err)
+	}
+	if best, ok := pr.Best(p6); !ok || best.Peer != b {
+		t.Errorf("expected peer b's route back, got %+v", best)
+	}
+
+	unknown := netip.MustParseAddr("198.51.100.1")
+	if err := pr.Insert(unknown, rib.Route{Prefix: p4}); !errors.Is(err, rib.ErrUnknownPeer) {
+		t.Errorf("expected ErrUnknownPeer, got %v", err)
+	}
+	if _, err := pr.PeerDown(unknown); !errors.Is(err, rib.ErrUnknownPeer) {
+		t.Errorf("expected ErrUnknownPeer, got %v", err)
+	}
+}
+
+func TestPeerRibTablesNotReset(t *testing.T) {
+	pr := rib.NewPeerRib()
+	a := rib.Peer{ASN: 174, Address: netip.MustParseAddr("192.0.2.1")}
+	pr.AddPeer(a)
+	attrs := &rib.RouteAttributes{AsPath: []uint32{174, 13335}}
+	pr.Insert(a.Address, rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24"), Attributes: attrs})
+
+	adjIn, _ := pr.AdjRibIn(a.Address)
+	other := rib.GetNewRib()
+	for name, fn := range map[string]func(){
+		"adj-in reset":        adjIn.Reset,
+		"loc reset":           pr.LocRib().Reset,
+		"adj-in replace":      func() { adjIn.Replace(&other) },
+		"loc replace":         func() { pr.LocRib().Replace(&other) },
+		"replace from adj-in": func() { other.Replace(adjIn) },
+		"replace from loc":    func() { other.Replace(pr.LocRib()) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+
+	if adjIn.V4Count() != 1 || pr.LocRib().V4Count() != 1 {
+		t.Errorf("expected routes kept, got %d and %d", adjIn.V4Count(), pr.LocRib().V4Count())
+	}
+	if got := pr.LocRib().Stats(0).UniqueAttributes; got != 1 {
+		t.Errorf("expected 1 shared attribute set, got %d", got)
+	}
+	pr.PeerDown(a.Address)
+	if got := pr.LocRib().Stats(0).UniqueAttributes; got != 0 {
+		t.Errorf("expected attributes released after PeerDown, got %d", got)
+	}
+}
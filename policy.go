@@ -0,0 +1,279 @@
+package routing_table
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// PolicyResult is the outcome of a policy term.
+type PolicyResult uint8
+
+const (
+	// PolicyNext continues with the next term after applying the actions.
+	PolicyNext PolicyResult = iota
+	// PolicyAccept accepts the route with the actions applied so far.
+	PolicyAccept
+	// PolicyReject drops the route.
+	PolicyReject
+)
+
+func (p PolicyResult) String() string {
+	switch p {
+	case PolicyNext:
+		return "next"
+	case PolicyAccept:
+		return "accept"
+	case PolicyReject:
+		return "reject"
+	}
+	return fmt.Sprintf("PolicyResult(%d)", uint8(p))
+}
+
+// PolicyAction modifies the attributes of a route matched by a term.
+type PolicyAction struct {
+	name  string
+	apply func(*RouteAttributes)
+}
+
+func (a PolicyAction) String() string {
+	return a.name
+}
+
+// SetLocalPref sets the LocalPref attribute.
+func SetLocalPref(pref uint32) PolicyAction {
+	return PolicyAction{fmt.Sprintf("set local-preference %d", pref), func(a *RouteAttributes) {
+		a.LocalPref = pref
+	}}
+}
+
+// Prepend prepends asn to the AS path count times. A count below zero is
+// treated as zero.
+func Prepend(asn uint32, count int) PolicyAction {
+	count = max(count, 0)
+	return PolicyAction{fmt.Sprintf("prepend %d x%d", asn, count), func(a *RouteAttributes) {
+		path := make([]uint32, 0, count+len(a.AsPath))
+		for i := 0; i < count; i++ {
+			path = append(path, asn)
+		}
+		a.AsPath = append(path, a.AsPath...)
+	}}
+}
+
+// StripPrivateASNs removes private use ASNs (RFC 6996) from the AS path.
+func StripPrivateASNs() PolicyAction {
+	return PolicyAction{"strip private-as", func(a *RouteAttributes) {
+		a.AsPath = slices.DeleteFunc(a.AsPath, isPrivateASN)
+	}}
+}
+
+func isPrivateASN(asn uint32) bool {
+	return (asn >= 64512 && asn <= 65534) || (asn >= 4200000000 && asn <= 4294967294)
+}
+
+// AddCommunity adds a standard community, if not already present.
+func AddCommunity(c uint32) PolicyAction {
+	return PolicyAction{"add community " + formatCommunity(c), func(a *RouteAttributes) {
+		if !slices.Contains(a.Communities, c) {
+			a.Communities = append(a.Communities, c)
+		}
+	}}
+}
+
+// RemoveCommunity removes a standard community.
+func RemoveCommunity(c uint32) PolicyAction {
+	return PolicyAction{"remove community " + formatCommunity(c), func(a *RouteAttributes) {
+		a.Communities = slices.DeleteFunc(a.Communities, func(v uint32) bool { return v == c })
+	}}
+}
+
+// AddLargeCommunity adds a large community, if not already present.
+func AddLargeCommunity(c LargeCommunity) PolicyAction {
+	name := fmt.Sprintf("add large-community %d:%d:%d", c.GlobalAdmin, c.LocalData1, c.LocalData2)
+	return PolicyAction{name, func(a *RouteAttributes) {
+		if !slices.Contains(a.LargeCommunities, c) {
+			a.LargeCommunities = append(a.LargeCommunities, c)
+		}
+	}}
+}
+
+func formatCommunity(c uint32) string {
+	return fmt.Sprintf("%d:%d", c>>16, c&0xFFFF)
+}
+
+// PolicyTerm is one ordered entry of a policy, like a route-map sequence. A
+// route matches when every condition that is set matches; a term without
+// conditions matches every route.
+type PolicyTerm struct {
+	Name string
+
+	// Match is a filter expression the route must match.
+	Match *Filter
+	// PrefixList must permit the route's prefix.
+	PrefixList *PrefixList
+
+	// Actions are applied in order to a matching route.
+	Actions []PolicyAction
+	// Result decides what happens to a matching route after the actions.
+	Result PolicyResult
+}
+
+func (t *PolicyTerm) matches(route Route) bool {
+	if t.Match != nil && !t.Match.Match(route) {
+		return false
+	}
+	if t.PrefixList != nil && !t.PrefixList.Permits(route.Prefix) {
+		return false
+	}
+	return true
+}
+
+// Policy is an ordered list of terms evaluated against a route until one
+// accepts or rejects it. A route no term decides gets Default, which is
+// PolicyAccept or PolicyReject; PolicyNext is treated as accept.
+//
+// A policy must not be modified once it is installed on a RIB.
+type Policy struct {
+	Name    string
+	Terms   []PolicyTerm
+	Default PolicyResult
+}
+
+// Evaluate runs the policy over route and returns the resulting route and
+// whether it is accepted. The input attributes are never modified: as soon as
+// an action applies, the returned route carries a private copy, so Evaluate is
+// safe to use on routes read from a RIB, whose attributes are shared.
+func (p *Policy) Evaluate(route Route) (Route, bool) {
+	copied := false
+	for i := range p.Terms {
+		t := &p.Terms[i]
+		if !t.matches(route) {
+			continue
+		}
+		if t.Result == PolicyReject {
+			return route, false
+		}
+		if len(t.Actions) > 0 && !copied {
+			route.Attributes = copyAttributes(route.Attributes)
+			copied = true
+		}
+		for _, a := range t.Actions {
+			a.apply(route.Attributes)
+		}
+		if t.Result == PolicyAccept {
+			return route, true
+		}
+	}
+	return route, p.Default != PolicyReject
+}
+
+func (p *Policy) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "policy %s\n", p.Name)
+	for i, t := range p.Terms {
+		fmt.Fprintf(&b, "  term %d", (i+1)*10)
+		if t.Name != "" {
+			fmt.Fprintf(&b, " %s", t.Name)
+		}
+		b.WriteByte('\n')
+		if t.Match != nil {
+			fmt.Fprintf(&b, "    match %s\n", t.Match)
+		}
+		if t.PrefixList != nil {
+			fmt.Fprintf(&b, "    match prefix-list %s\n", t.PrefixList.Name)
+		}
+		for _, a := range t.Actions {
+			fmt.Fprintf(&b, "    %s\n", a)
+		}
+		fmt.Fprintf(&b, "    %s\n", t.Result)
+	}
+	def := PolicyAccept
+	if p.Default == PolicyReject {
+		def = PolicyReject
+	}
+	fmt.Fprintf(&b, "  default %s\n", def)
+	return b.String()
+}
+
+// copyAttributes returns an uninterned deep copy of attr, or empty attributes
+// for nil.
+func copyAttributes(attr *RouteAttributes) *RouteAttributes {
+	if attr == nil {
+		return &RouteAttributes{}
+	}
+	return &RouteAttributes{
+		AsPath:           slices.Clone(attr.AsPath),
+		Communities:      slices.Clone(attr.Communities),
+		LargeCommunities: slices.Clone(attr.LargeCommunities),
+		LocalPref:        attr.LocalPref,
+	}
+}
+
+// SetImportPolicy installs p as the import policy for both address families.
+// Every inserted route is evaluated before its attributes are interned, so
+// the RIB stores the modified attributes. A rejected update also withdraws
+// any path previously stored for the same prefix and PathID. Passing nil
+// removes the policy; routes already in the RIB are not re-evaluated.
+func (r *Rib) SetImportPolicy(p *Policy) {
+	r.v4mu.Lock()
+	r.v6mu.Lock()
+	defer r.v4mu.Unlock()
+	defer r.v6mu.Unlock()
+	r.importPolicy = p
+}
+
+// applyImportPolicy evaluates the import policy for route. The caller must
+// hold the lock of the route's address family.
+func (r *Rib) applyImportPolicy(route Route) (Route, bool) {
+	if r.importPolicy == nil {
+		return route, true
+	}
+	return r.importPolicy.Evaluate(route)
+}
+
+// Export evaluates p over the best path of every prefix and returns the
+// accepted routes with their modified attributes, IPv4 before IPv6 in address
+// order, as they would be advertised to a neighbor. The RIB's own attributes
+// are left untouched.
+func (r *Rib) Export(p *Policy) (v4 []Route, v6 []Route) {
+	export := func(out *[]Route) func(netip.Prefix, *node) {
+		return func(prefix netip.Prefix, n *node) {
+			best := n.bestPath()
+			if best == nil {
+				return
+			}
+			if rt, ok := p.Evaluate(Route{Prefix: prefix, Attributes: best, PathID: bestPathID(n, best)}); ok {
+				*out = append(*out, rt)
+			}
+		}
+	}
+
+	r.v4mu.RLock()
+	r.walkIPv4(export(&v4))
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(export(&v6))
+	r.v6mu.RUnlock()
+
+	return v4, v6
+}
+
+// bestPathID returns the PathID on n holding best that bestPath selected: the
+// lowest unsuppressed one, preferring paths that are not stale.
+func bestPathID(n *node, best *RouteAttributes) uint32 {
+	var id uint32
+	var idStale bool
+	found := false
+	for pid, attr := range n.paths {
+		if attr != best || n.suppressed(pid) {
+			continue
+		}
+		stale := n.stale(pid)
+		if !found || (idStale && !stale) || (stale == idStale && pid < id) {
+			id, idStale, found = pid, stale, true
+		}
+	}
+	return id
+}
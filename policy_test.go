@@ -0,0 +1,97 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	customers := rib.NewPrefixList("CUSTOMERS")
+	customers.Add(rib.PrefixListEntry{Prefix: netip.MustParsePrefix("20.0.0.0/8"), MinLen: 8, MaxLen: 24})
+	backup, _ := rib.ParseCommunity("64500:80")
+
+	p := &rib.Policy{
+		Name: "IMPORT",
+		Terms: []rib.PolicyTerm{
+			{Name: "no-small", Match: rib.MustParseFilter("prefixlen > 48"), Result: rib.PolicyReject},
+			{Name: "backup", Match: rib.MustParseFilter("community 64500:80"), Actions: []rib.PolicyAction{rib.SetLocalPref(80), rib.RemoveCommunity(backup)}, Result: rib.PolicyNext},
+			{Name: "clean", Actions: []rib.PolicyAction{rib.StripPrivateASNs()}, Result: rib.PolicyNext},
+			{Name: "customers", PrefixList: customers, Actions: []rib.PolicyAction{rib.SetLocalPref(200)}, Result: rib.PolicyAccept},
+			{Name: "prepend", Match: rib.MustParseFilter("family ipv6"), Actions: []rib.PolicyAction{rib.Prepend(64499, 2)}, Result: rib.PolicyAccept},
+		},
+		Default: rib.PolicyReject,
+	}
+
+	in := &rib.RouteAttributes{AsPath: []uint32{174, 65001, 64500}, Communities: []uint32{backup}, LocalPref: 100}
+	out, ok := p.Evaluate(rib.Route{Prefix: netip.MustParsePrefix("30.0.0.0/16"), Attributes: in})
+	if ok {
+		t.Errorf("expected default reject, got %v", out.Attributes)
+	}
+
+	out, ok = p.Evaluate(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32"), Attributes: in})
+	if !ok {
+		t.Fatal("expected IPv6 route accepted")
+	}
+	if want := []uint32{64499, 64499, 174, 64500}; !reflect.DeepEqual(out.Attributes.AsPath, want) || out.Attributes.LocalPref != 80 || len(out.Attributes.Communities) != 0 {
+		t.Errorf("unexpected attributes %+v", out.Attributes)
+	}
+	if len(in.AsPath) != 3 || len(in.Communities) != 1 || in.LocalPref != 100 {
+		t.Errorf("input attributes modified: %+v", in)
+	}
+
+	if _, ok := p.Evaluate(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/30")}); !ok {
+		t.Error("expected route without attributes accepted")
+	}
+	if !strings.Contains(p.String(), "term 20 backup\n    match community 64500:80\n    set local-preference 80\n") {
+		t.Errorf("unexpected policy text:\n%s", p)
+	}
+}
+
+func TestImportExportPolicy(t *testing.T) {
+	router := rib.GetNewRib()
+	router.SetImportPolicy(&rib.Policy{
+		Terms: []rib.PolicyTerm{
+			{Match: rib.MustParseFilter("aspath contains 64666"), Result: rib.PolicyReject},
+			{Match: rib.MustParseFilter("community 64500:200"), Actions: []rib.PolicyAction{rib.SetLocalPref(200)}},
+		},
+	})
+
+	high, _ := rib.ParseCommunity("64500:200")
+	prefix := netip.MustParsePrefix("10.0.0.0/8")
+	router.InsertIPv4(rib.Route{Prefix: prefix, Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 64500}, Communities: []uint32{high}}})
+	router.InsertIPv4(rib.Route{Prefix: prefix, Attributes: &rib.RouteAttributes{AsPath: []uint32{3356, 64500}}, PathID: 1})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("20.0.0.0/8"), Attributes: &rib.RouteAttributes{AsPath: []uint32{64666}}})
+
+	if router.V4Count() != 1 {
+		t.Fatalf("expected rejected route dropped, got %d prefixes", router.V4Count())
+	}
+	best := router.LookupIPv4(prefix)
+	if best == nil || best.Attributes.LocalPref != 200 || best.Attributes.AsPath[0] != 174 {
+		t.Fatalf("expected policy-set LocalPref on best path, got %+v", best)
+	}
+
+	// A later update rejected by policy withdraws the stored path.
+	router.InsertIPv4(rib.Route{Prefix: prefix, Attributes: &rib.RouteAttributes{AsPath: []uint32{3356, 64666}}, PathID: 1})
+	if got := router.AllPathsIPv4(prefix); len(got) != 1 {
+		t.Errorf("expected path 1 withdrawn, got %v", got)
+	}
+
+	export := &rib.Policy{Terms: []rib.PolicyTerm{{Actions: []rib.PolicyAction{rib.Prepend(64510, 1), rib.SetLocalPref(0)}}}}
+	v4, v6 := router.Export(export)
+	if len(v4) != 1 || len(v6) != 0 || v4[0].Attributes.AsPath[0] != 64510 || v4[0].Attributes.LocalPref != 0 {
+		t.Fatalf("unexpected export %v %v", v4, v6)
+	}
+	if stored := router.LookupIPv4(prefix); stored.Attributes.AsPath[0] != 174 || stored.Attributes.LocalPref != 200 {
+		t.Errorf("export modified stored attributes: %+v", stored.Attributes)
+	}
+
+	// A negative prepend count prepends nothing rather than panicking.
+	v4, _ = router.Export(&rib.Policy{Terms: []rib.PolicyTerm{{Actions: []rib.PolicyAction{rib.Prepend(64510, -1)}}}})
+	if len(v4) != 1 || v4[0].Attributes.AsPath[0] != 174 {
+		t.Errorf("unexpected export with negative prepend %v", v4)
+	}
+}
@@ -0,0 +1,574 @@
+package routing_table
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrefixListFormat selects the text syntax used to load and save a PrefixList.
+type PrefixListFormat int
+
+const (
+	// PrefixListCisco is the IOS/FRR syntax:
+	//   ip prefix-list NAME seq 5 permit 10.0.0.0/8 le 24
+	//   ipv6 prefix-list NAME seq 10 deny 2001:db8::/32 ge 40 le 48
+	PrefixListCisco PrefixListFormat = iota
+
+	// PrefixListJunos is one Junos route-filter per line:
+	//   route-filter 10.0.0.0/8 upto /24;
+	//   route-filter 2001:db8::/32 prefix-length-range /40-/48 reject;
+	// Bare "10.0.0.0/8;" lines, as found in a Junos prefix-list, match exactly.
+	PrefixListJunos
+
+	// PrefixListBird is a BIRD prefix set:
+	//   define NAME = [ 10.0.0.0/8{8,24}, 192.168.0.0/16+, 2001:db8::/32{40,48} ];
+	PrefixListBird
+)
+
+func (f PrefixListFormat) String() string {
+	switch f {
+	case PrefixListCisco:
+		return "cisco"
+	case PrefixListJunos:
+		return "junos"
+	case PrefixListBird:
+		return "bird"
+	}
+	return "PrefixListFormat(" + strconv.Itoa(int(f)) + ")"
+}
+
+// PrefixListEntry is one line of a prefix-list. It matches any prefix inside
+// Prefix whose length is between MinLen and MaxLen inclusive.
+type PrefixListEntry struct {
+	Seq    uint32
+	Prefix netip.Prefix
+	MinLen int
+	MaxLen int
+	Deny   bool
+}
+
+// covers reports whether the entry matches p. The caller guarantees that p
+// lies within e.Prefix.
+func (e *PrefixListEntry) covers(p netip.Prefix) bool {
+	return p.Bits() >= e.MinLen && p.Bits() <= e.MaxLen
+}
+
+// PrefixList is an ordered list of permit/deny entries with ge/le length
+// ranges. Entries are indexed in a per-family binary trie keyed on their
+// prefix, so matching walks at most one node per bit of the candidate.
+//
+// As on a router, the matching entry with the lowest sequence number decides
+// the result and a prefix matching no entry is denied.
+type PrefixList struct {
+	Name string
+
+	v4root *plNode
+	v6root *plNode
+	count  int
+	maxSeq uint32
+}
+
+type plNode struct {
+	children [2]*plNode
+	entries  []PrefixListEntry
+}
+
+// NewPrefixList returns an empty prefix-list.
+func NewPrefixList(name string) *PrefixList {
+	return &PrefixList{Name: name}
+}
+
+// Add inserts an entry. A zero Seq is replaced by the next multiple of 5 after
+// the highest sequence seen so far. A zero MinLen and MaxLen select an exact
+// match on the entry's prefix length.
+func (pl *PrefixList) Add(e PrefixListEntry) error {
+	if !e.Prefix.IsValid() {
+		return fmt.Errorf("invalid prefix")
+	}
+	e.Prefix = e.Prefix.Masked()
+	maxBits := e.Prefix.Addr().BitLen()
+	if e.MinLen == 0 && e.MaxLen == 0 {
+		e.MinLen, e.MaxLen = e.Prefix.Bits(), e.Prefix.Bits()
+	}
+	if e.MinLen < e.Prefix.Bits() || e.MaxLen < e.MinLen || e.MaxLen > maxBits {
+		return fmt.Errorf("invalid length range %d-%d for %s", e.MinLen, e.MaxLen, e.Prefix)
+	}
+	if e.Seq == 0 {
+		e.Seq = (pl.maxSeq/5 + 1) * 5
+	}
+	if e.Seq > pl.maxSeq {
+		pl.maxSeq = e.Seq
+	}
+
+	root := &pl.v4root
+	if e.Prefix.Addr().Is6() {
+		root = &pl.v6root
+	}
+	if *root == nil {
+		*root = &plNode{}
+	}
+	n := *root
+	addr := e.Prefix.Addr().AsSlice()
+	for i := 0; i < e.Prefix.Bits(); i++ {
+		bit := addrBit(addr, i)
+		if n.children[bit] == nil {
+			n.children[bit] = &plNode{}
+		}
+		n = n.children[bit]
+	}
+	n.entries = append(n.entries, e)
+	pl.count++
+	return nil
+}
+
+// Len returns the number of entries in the list.
+func (pl *PrefixList) Len() int {
+	return pl.count
+}
+
+// Entries returns all entries ordered by sequence number, IPv4 before IPv6.
+func (pl *PrefixList) Entries() []PrefixListEntry {
+	entries := make([]PrefixListEntry, 0, pl.count)
+	var collect func(n *plNode)
+	collect = func(n *plNode) {
+		if n == nil {
+			return
+		}
+		entries = append(entries, n.entries...)
+		collect(n.children[0])
+		collect(n.children[1])
+	}
+	collect(pl.v4root)
+	collect(pl.v6root)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Seq != entries[j].Seq {
+			return entries[i].Seq < entries[j].Seq
+		}
+		return entries[i].Prefix.Addr().Is4() && entries[j].Prefix.Addr().Is6()
+	})
+	return entries
+}
+
+// Lookup returns the entry that decides p, if any. It walks the trie along
+// the bits of p, so the cost is bounded by the prefix length. An invalid
+// prefix matches no entry.
+func (pl *PrefixList) Lookup(p netip.Prefix) (PrefixListEntry, bool) {
+	if !p.IsValid() {
+		return PrefixListEntry{}, false
+	}
+	n := pl.v4root
+	if p.Addr().Is6() {
+		n = pl.v6root
+	}
+	p = p.Masked()
+	addr := p.Addr().AsSlice()
+
+	var best *PrefixListEntry
+	for depth := 0; n != nil; depth++ {
+		for i := range n.entries {
+			e := &n.entries[i]
+			if e.covers(p) && (best == nil || e.Seq < best.Seq) {
+				best = e
+			}
+		}
+		if depth == p.Bits() {
+			break
+		}
+		n = n.children[addrBit(addr, depth)]
+	}
+	if best == nil {
+		return PrefixListEntry{}, false
+	}
+	return *best, true
+}
+
+// Permits reports whether the list permits p.
+func (pl *PrefixList) Permits(p netip.Prefix) bool {
+	e, ok := pl.Lookup(p)
+	return ok && !e.Deny
+}
+
+// addrBit returns bit i (0 = most significant) of a big-endian address.
+func addrBit(addr []byte, i int) int {
+	return int(addr[i/8]>>(7-uint(i%8))) & 1
+}
+
+// MatchPrefixList walks the entire RIB and splits every path into those whose
+// prefix the list permits and those it denies, including prefixes no entry covers.
+func (r *Rib) MatchPrefixList(pl *PrefixList) (permitted []Route, denied []Route) {
+	check := func(prefix netip.Prefix, n *node) {
+		ok := pl.Permits(prefix)
+		for id := range n.paths {
+			rt := n.route(prefix, id)
+			if ok {
+				permitted = append(permitted, rt)
+			} else {
+				denied = append(denied, rt)
+			}
+		}
+	}
+
+	r.v4mu.RLock()
+	r.walkIPv4(check)
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(check)
+	r.v6mu.RUnlock()
+
+	return permitted, denied
+}
+
+// ParsePrefixList reads a prefix-list in the given format. The list name is
+// taken from the input where the format carries one.
+func ParsePrefixList(rd io.Reader, format PrefixListFormat) (*PrefixList, error) {
+	switch format {
+	case PrefixListCisco, PrefixListJunos:
+	case PrefixListBird:
+		return parseBirdPrefixSet(rd)
+	default:
+		return nil, fmt.Errorf("unknown prefix-list format %d", format)
+	}
+
+	pl := NewPrefixList("")
+	sc := bufio.NewScanner(rd)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '!' || line[0] == '#' || strings.HasPrefix(line, "/*") {
+			continue
+		}
+		var err error
+		if format == PrefixListCisco {
+			err = pl.parseCiscoLine(line)
+		} else {
+			err = pl.parseJunosLine(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+func (pl *PrefixList) parseCiscoLine(line string) error {
+	f := strings.Fields(line)
+	if len(f) < 3 || (f[0] != "ip" && f[0] != "ipv6") || f[1] != "prefix-list" {
+		return fmt.Errorf("not a prefix-list line: %q", line)
+	}
+	if pl.Name == "" {
+		pl.Name = f[2]
+	}
+	f = f[3:]
+	if len(f) > 0 && f[0] == "description" {
+		return nil
+	}
+
+	var e PrefixListEntry
+	if len(f) >= 2 && f[0] == "seq" {
+		seq, err := strconv.ParseUint(f[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid seq %q", f[1])
+		}
+		e.Seq = uint32(seq)
+		f = f[2:]
+	}
+	if len(f) < 2 || (f[0] != "permit" && f[0] != "deny") {
+		return fmt.Errorf("expected permit or deny: %q", line)
+	}
+	e.Deny = f[0] == "deny"
+	p, err := netip.ParsePrefix(f[1])
+	if err != nil {
+		return err
+	}
+	e.Prefix = p
+	e.MinLen, e.MaxLen = p.Bits(), p.Bits()
+
+	// ge alone extends to the host length; le alone starts at the prefix length.
+	hasGe := false
+	for f = f[2:]; len(f) >= 2; f = f[2:] {
+		n, err := strconv.Atoi(f[1])
+		if err != nil {
+			return fmt.Errorf("invalid length %q", f[1])
+		}
+		switch f[0] {
+		case "ge":
+			e.MinLen, hasGe = n, true
+			e.MaxLen = p.Addr().BitLen()
+		case "le":
+			e.MaxLen = n
+			if !hasGe {
+				e.MinLen = p.Bits()
+			}
+		default:
+			return fmt.Errorf("unexpected %q", f[0])
+		}
+	}
+	if len(f) != 0 {
+		return fmt.Errorf("trailing %q", f[0])
+	}
+	return pl.Add(e)
+}
+
+func (pl *PrefixList) parseJunosLine(line string) error {
+	line = strings.TrimSuffix(strings.TrimSpace(line), ";")
+	f := strings.Fields(line)
+	if len(f) == 0 || f[0] == "}" || strings.HasSuffix(line, "{") {
+		// Block structure such as "prefix-list NAME {" carries no entries.
+		if len(f) >= 2 && f[0] == "prefix-list" && pl.Name == "" {
+			pl.Name = f[1]
+		}
+		return nil
+	}
+	if f[0] == "route-filter" {
+		f = f[1:]
+	} else if len(f) != 1 {
+		return fmt.Errorf("not a route-filter line: %q", line)
+	}
+
+	p, err := netip.ParsePrefix(f[0])
+	if err != nil {
+		return err
+	}
+	e := PrefixListEntry{Prefix: p, MinLen: p.Bits(), MaxLen: p.Bits()}
+	f = f[1:]
+	if len(f) > 0 {
+		switch f[0] {
+		case "exact":
+		case "orlonger":
+			e.MaxLen = p.Addr().BitLen()
+		case "longer":
+			e.MinLen, e.MaxLen = p.Bits()+1, p.Addr().BitLen()
+		case "upto":
+			if len(f) < 2 {
+				return fmt.Errorf("upto needs a length")
+			}
+			if e.MaxLen, err = parseSlashLen(f[1]); err != nil {
+				return err
+			}
+			f = f[1:]
+		case "prefix-length-range":
+			if len(f) < 2 {
+				return fmt.Errorf("prefix-length-range needs a range")
+			}
+			lo, hi, ok := strings.Cut(f[1], "-")
+			if !ok {
+				return fmt.Errorf("invalid prefix-length-range %q", f[1])
+			}
+			if e.MinLen, err = parseSlashLen(lo); err != nil {
+				return err
+			}
+			if e.MaxLen, err = parseSlashLen(hi); err != nil {
+				return err
+			}
+			f = f[1:]
+		default:
+			return fmt.Errorf("unknown match type %q", f[0])
+		}
+		f = f[1:]
+	}
+	if len(f) > 0 {
+		switch f[0] {
+		case "accept":
+		case "reject":
+			e.Deny = true
+		default:
+			return fmt.Errorf("unknown action %q", f[0])
+		}
+	}
+	return pl.Add(e)
+}
+
+func parseSlashLen(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(s, "/"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid prefix length %q", s)
+	}
+	return n, nil
+}
+
+func parseBirdPrefixSet(rd io.Reader) (*PrefixList, error) {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	pl := NewPrefixList("")
+
+	start := strings.IndexByte(text, '[')
+	end := strings.LastIndexByte(text, ']')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("prefix set must be enclosed in [ ... ]")
+	}
+	if head := strings.Fields(text[:start]); len(head) >= 2 && head[0] == "define" {
+		pl.Name = head[1]
+	}
+
+	for _, item := range splitPrefixSet(text[start+1 : end]) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var e PrefixListEntry
+		body := item
+		switch {
+		case strings.HasSuffix(item, "+"):
+			body = item[:len(item)-1]
+		case strings.HasSuffix(item, "-"):
+			body = item[:len(item)-1]
+		case strings.HasSuffix(item, "}"):
+			i := strings.IndexByte(item, '{')
+			if i < 0 {
+				return nil, fmt.Errorf("invalid prefix pattern %q", item)
+			}
+			body = item[:i]
+			lo, hi, ok := strings.Cut(item[i+1:len(item)-1], ",")
+			if !ok {
+				return nil, fmt.Errorf("invalid length range in %q", item)
+			}
+			if e.MinLen, err = strconv.Atoi(strings.TrimSpace(lo)); err != nil {
+				return nil, fmt.Errorf("invalid length range in %q", item)
+			}
+			if e.MaxLen, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+				return nil, fmt.Errorf("invalid length range in %q", item)
+			}
+		}
+		p, err := netip.ParsePrefix(strings.TrimSpace(body))
+		if err != nil {
+			return nil, err
+		}
+		e.Prefix = p
+		switch {
+		case strings.HasSuffix(item, "+"):
+			e.MinLen, e.MaxLen = p.Bits(), p.Addr().BitLen()
+		case strings.HasSuffix(item, "-"):
+			e.MinLen, e.MaxLen = 0, p.Bits()
+		case !strings.HasSuffix(item, "}"):
+			e.MinLen, e.MaxLen = p.Bits(), p.Bits()
+		}
+		// Lengths shorter than the prefix match its ancestors, e.g.
+		// 1.2.0.0/16{8,16} matches 1.0.0.0/8 through 1.2.0.0/16.
+		for ; e.MinLen < p.Bits() && e.MinLen <= e.MaxLen; e.MinLen++ {
+			ancestor := netip.PrefixFrom(p.Addr(), e.MinLen)
+			if err := pl.Add(PrefixListEntry{Prefix: ancestor, MinLen: e.MinLen, MaxLen: e.MinLen}); err != nil {
+				return nil, err
+			}
+		}
+		if e.MinLen > e.MaxLen {
+			continue
+		}
+		if err := pl.Add(e); err != nil {
+			return nil, err
+		}
+	}
+	return pl, nil
+}
+
+// splitPrefixSet splits the body of a BIRD prefix set on the commas that
+// separate items, leaving those inside "{low,high}" intact.
+func splitPrefixSet(s string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(items, s[start:])
+}
+
+// Write saves the list in the given format. BIRD prefix sets cannot express
+// deny entries; writing a list that contains any returns an error.
+func (pl *PrefixList) Write(w io.Writer, format PrefixListFormat) error {
+	name := pl.Name
+	if name == "" {
+		name = "PL"
+	}
+	bw := bufio.NewWriter(w)
+	entries := pl.Entries()
+
+	switch format {
+	case PrefixListCisco:
+		for _, e := range entries {
+			family := "ip"
+			if e.Prefix.Addr().Is6() {
+				family = "ipv6"
+			}
+			action := "permit"
+			if e.Deny {
+				action = "deny"
+			}
+			fmt.Fprintf(bw, "%s prefix-list %s seq %d %s %s", family, name, e.Seq, action, e.Prefix)
+			maxBits := e.Prefix.Addr().BitLen()
+			switch {
+			case e.MinLen == e.Prefix.Bits() && e.MaxLen == e.Prefix.Bits():
+			case e.MinLen == e.Prefix.Bits():
+				fmt.Fprintf(bw, " le %d", e.MaxLen)
+			case e.MaxLen == maxBits:
+				fmt.Fprintf(bw, " ge %d", e.MinLen)
+			default:
+				fmt.Fprintf(bw, " ge %d le %d", e.MinLen, e.MaxLen)
+			}
+			bw.WriteByte('\n')
+		}
+
+	case PrefixListJunos:
+		for _, e := range entries {
+			fmt.Fprintf(bw, "route-filter %s ", e.Prefix)
+			maxBits := e.Prefix.Addr().BitLen()
+			switch {
+			case e.MinLen == e.Prefix.Bits() && e.MaxLen == e.Prefix.Bits():
+				bw.WriteString("exact")
+			case e.MinLen == e.Prefix.Bits() && e.MaxLen == maxBits:
+				bw.WriteString("orlonger")
+			case e.MinLen == e.Prefix.Bits()+1 && e.MaxLen == maxBits:
+				bw.WriteString("longer")
+			case e.MinLen == e.Prefix.Bits():
+				fmt.Fprintf(bw, "upto /%d", e.MaxLen)
+			default:
+				fmt.Fprintf(bw, "prefix-length-range /%d-/%d", e.MinLen, e.MaxLen)
+			}
+			if e.Deny {
+				bw.WriteString(" reject;\n")
+			} else {
+				bw.WriteString(" accept;\n")
+			}
+		}
+
+	case PrefixListBird:
+		items := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if e.Deny {
+				return fmt.Errorf("bird prefix sets cannot express deny entry %s", e.Prefix)
+			}
+			switch {
+			case e.MinLen == e.Prefix.Bits() && e.MaxLen == e.Prefix.Bits():
+				items = append(items, e.Prefix.String())
+			case e.MinLen == e.Prefix.Bits() && e.MaxLen == e.Prefix.Addr().BitLen():
+				items = append(items, e.Prefix.String()+"+")
+			default:
+				items = append(items, fmt.Sprintf("%s{%d,%d}", e.Prefix, e.MinLen, e.MaxLen))
+			}
+		}
+		fmt.Fprintf(bw, "define %s = [\n\t%s\n];\n", name, strings.Join(items, ",\n\t"))
+
+	default:
+		return fmt.Errorf("unknown prefix-list format %d", format)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,172 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestPrefixListMatch(t *testing.T) {
+	pl := rib.NewPrefixList("CUSTOMER")
+	entries := []rib.PrefixListEntry{
+		{Seq: 5, Prefix: netip.MustParsePrefix("10.1.0.0/16"), MinLen: 16, MaxLen: 16, Deny: true},
+		{Seq: 10, Prefix: netip.MustParsePrefix("10.0.0.0/8"), MinLen: 8, MaxLen: 24},
+		{Seq: 20, Prefix: netip.MustParsePrefix("2001:db8::/32"), MinLen: 40, MaxLen: 48},
+		{Seq: 30, Prefix: netip.MustParsePrefix("192.0.2.0/24")},
+	}
+	for _, e := range entries {
+		if err := pl.Add(e); err != nil {
+			t.Fatalf("Add(%v): %v", e, err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		permit bool
+	}{
+		{"10.0.0.0/8", true},
+		{"10.2.3.0/24", true},
+		{"10.2.3.0/25", false},   // longer than le 24
+		{"10.1.0.0/16", false},   // lower seq deny wins
+		{"10.1.1.0/24", true},    // deny is exact, so the le 24 entry matches
+		{"11.0.0.0/8", false},    // implicit deny
+		{"2001:db8::/32", false}, // shorter than ge 40
+		{"2001:db8:ff00::/40", true},
+		{"2001:db8:1::/48", true},
+		{"2001:db8:1:1::/64", false},
+		{"192.0.2.0/24", true},
+		{"192.0.2.0/25", false},
+	}
+	for _, tc := range tests {
+		if got := pl.Permits(netip.MustParsePrefix(tc.prefix)); got != tc.permit {
+			t.Errorf("Permits(%s) = %v, want %v", tc.prefix, got, tc.permit)
+		}
+	}
+	if _, ok := pl.Lookup(netip.Prefix{}); ok || pl.Permits(netip.Prefix{}) {
+		t.Error("expected the invalid prefix to match no entry")
+	}
+}
+
+func TestPrefixListAddErrors(t *testing.T) {
+	pl := rib.NewPrefixList("X")
+	bad := []rib.PrefixListEntry{
+		{},
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), MinLen: 4, MaxLen: 24},
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), MinLen: 24, MaxLen: 16},
+		{Prefix: netip.MustParsePrefix("10.0.0.0/8"), MinLen: 8, MaxLen: 33},
+	}
+	for _, e := range bad {
+		if err := pl.Add(e); err == nil {
+			t.Errorf("expected error adding %+v", e)
+		}
+	}
+}
+
+func TestPrefixListFormats(t *testing.T) {
+	tests := []struct {
+		format rib.PrefixListFormat
+		text   string
+	}{
+		{rib.PrefixListCisco, `
+! customer filter
+ip prefix-list CUST description customer
+ip prefix-list CUST seq 5 permit 10.0.0.0/8 le 24
+ip prefix-list CUST seq 10 deny 172.16.0.0/12 ge 13
+ipv6 prefix-list CUST seq 15 permit 2001:db8::/32 ge 40 le 48
+`},
+		{rib.PrefixListJunos, `
+route-filter 10.0.0.0/8 upto /24;
+route-filter 172.16.0.0/12 longer reject;
+route-filter 2001:db8::/32 prefix-length-range /40-/48;
+`},
+		{rib.PrefixListBird, `define CUST = [ 10.0.0.0/8{8,24}, 2001:db8::/32{40,48} ];`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format.String(), func(t *testing.T) {
+			pl, err := rib.ParsePrefixList(strings.NewReader(tc.text), tc.format)
+			if err != nil {
+				t.Fatalf("ParsePrefixList: %v", err)
+			}
+			check := func(pl *rib.PrefixList) {
+				t.Helper()
+				for p, want := range map[string]bool{
+					"10.0.0.0/8":         true,
+					"10.1.2.0/24":        true,
+					"10.1.2.0/25":        false,
+					"172.16.1.0/24":      false,
+					"2001:db8:aa00::/40": true,
+					"2001:db8::/32":      false,
+				} {
+					if got := pl.Permits(netip.MustParsePrefix(p)); got != want {
+						t.Errorf("Permits(%s) = %v, want %v", p, got, want)
+					}
+				}
+			}
+			check(pl)
+
+			// Round trip through every format that can express the list.
+			for _, out := range []rib.PrefixListFormat{rib.PrefixListCisco, rib.PrefixListJunos, rib.PrefixListBird} {
+				var sb strings.Builder
+				if err := pl.Write(&sb, out); err != nil {
+					if out == rib.PrefixListBird && tc.format != rib.PrefixListBird {
+						continue // deny entries cannot be written as a BIRD set
+					}
+					t.Fatalf("Write(%s): %v", out, err)
+				}
+				again, err := rib.ParsePrefixList(strings.NewReader(sb.String()), out)
+				if err != nil {
+					t.Fatalf("re-parsing %s output %q: %v", out, sb.String(), err)
+				}
+				if again.Len() != pl.Len() {
+					t.Errorf("%s round trip has %d entries, want %d", out, again.Len(), pl.Len())
+				}
+				check(again)
+			}
+		})
+	}
+}
+
+func TestPrefixListBirdAncestors(t *testing.T) {
+	pl, err := rib.ParsePrefixList(strings.NewReader("[ 1.2.0.0/16{8,16}, 10.0.0.0/8+, 192.168.1.0/24- ]"), rib.PrefixListBird)
+	if err != nil {
+		t.Fatalf("ParsePrefixList: %v", err)
+	}
+	for p, want := range map[string]bool{
+		"1.0.0.0/8":      true,
+		"1.2.0.0/15":     true,
+		"1.2.0.0/16":     true,
+		"1.3.0.0/16":     false,
+		"1.2.3.0/24":     false,
+		"10.9.9.0/24":    true,
+		"192.168.0.0/16": true,
+		"192.169.0.0/16": false,
+		"192.168.1.0/25": false,
+	} {
+		if got := pl.Permits(netip.MustParsePrefix(p)); got != want {
+			t.Errorf("Permits(%s) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestMatchPrefixList(t *testing.T) {
+	router := rib.GetNewRib()
+	for _, p := range []string{"10.0.0.0/8", "10.1.0.0/16", "11.0.0.0/8"} {
+		router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix(p)})
+	}
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8::/32")})
+
+	pl, err := rib.ParsePrefixList(strings.NewReader("ip prefix-list C permit 10.0.0.0/8 le 24"), rib.PrefixListCisco)
+	if err != nil {
+		t.Fatal(err)
+	}
+	permitted, denied := router.MatchPrefixList(pl)
+	if len(permitted) != 2 {
+		t.Errorf("expected 2 permitted routes, got %v", permitted)
+	}
+	if len(denied) != 2 {
+		t.Errorf("expected 2 denied routes, got %v", denied)
+	}
+}
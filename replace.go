@@ -0,0 +1,149 @@
+package routing_table
+
+import (
+	"net/netip"
+	"sync"
+)
+
+// NewReplacement returns an empty RIB with r's import filter, import policy,
+// bogon filter, VRP set, limits, timestamps and logger, to be filled in the
+// background and swapped in with Replace. Flap damping and the conflict hook
+// stay with r and are not applied while filling.
+func (r *Rib) NewReplacement() Rib {
+	c := GetNewRib()
+	r.v4mu.RLock()
+	defer r.v4mu.RUnlock()
+	c.importFilter = r.importFilter
+	c.importPolicy = r.importPolicy
+	c.bogonFilter = r.bogonFilter
+	c.vrps = r.vrps
+	c.limits = r.limits
+	c.timestamps = r.timestamps
+	c.logger = r.logger
+	return c
+}
+
+// Replace atomically replaces every route in r with the routes of next and
+// returns the path-level differences from the old table to the new one, in
+// the order of Diff. Readers of r see either the old or the new table, never
+// an empty or partial one. next is left empty.
+//
+// r keeps its configuration. Per-route state that depends on it moves with
+// the routes from next, which should be created with NewReplacement; RPKI
+// state is recomputed if next was built against a different VRP set. Paths
+// unchanged by the replacement keep their timestamps, changed paths keep
+// their first-seen time, and paths still suppressed by flap damping stay
+// suppressed. Graceful restart stale marks are not carried over. The
+// differences count towards Churn.
+//
+// Replace panics if r or next is a table of a PeerRib.
+func (r *Rib) Replace(next *Rib) []RouteDiff {
+	if r.peerOwned || next.peerOwned {
+		panic("Replace: table belongs to a PeerRib")
+	}
+	if r == next {
+		return nil
+	}
+	// Lock IPv4 before IPv6 and each pair in the order Diff uses.
+	for _, pair := range [][2]*sync.RWMutex{{r.v4mu, next.v4mu}, {r.v6mu, next.v6mu}} {
+		first, second := lockOrder(pair[0], pair[1])
+		first.Lock()
+		defer first.Unlock()
+		if second != nil {
+			second.Lock()
+			defer second.Unlock()
+		}
+	}
+
+	var diffs []RouteDiff
+	var v4, v6 churnCounts
+	collect := func(prefix netip.Prefix, a, b *node) bool {
+		if r.timestamps != nil && a != nil && b != nil && a.meta != nil {
+			keepTimestamps(a, b)
+		}
+		return diffPaths(prefix, a, b, func(d RouteDiff) bool {
+			churn := &v4
+			if prefix.Addr().Is6() {
+				churn = &v6
+			}
+			if d.Kind == DiffRemoved {
+				churn.withdrawals++
+			} else {
+				churn.updates++
+			}
+			diffs = append(diffs, d)
+			return true
+		})
+	}
+	r.diffIPv4Unlocked(next, collect)
+	r.diffIPv6Unlocked(next, collect)
+
+	r.ipv4Root, r.ipv6Root = next.ipv4Root, next.ipv6Root
+	r.v4Count, r.v6Count = next.v4Count, next.v6Count
+	r.v4PathCount, r.v6PathCount = next.v4PathCount, next.v6PathCount
+	r.v4NodeCount, r.v6NodeCount = next.v4NodeCount, next.v6NodeCount
+	r.v4masks, r.v6masks = next.v4masks, next.v6masks
+	r.attrTable = next.attrTable
+	r.v4Bogons, r.v6Bogons = next.v4Bogons, next.v6Bogons
+	r.v4Churn.updates += v4.updates
+	r.v4Churn.withdrawals += v4.withdrawals
+	r.v6Churn.updates += v6.updates
+	r.v6Churn.withdrawals += v6.withdrawals
+
+	r.v4RPKI, r.v6RPKI = next.v4RPKI, next.v6RPKI
+	r.v4Invalid, r.v6Invalid = next.v4Invalid, next.v6Invalid
+	if next.vrps != r.vrps {
+		r.v4RPKI, r.v6RPKI = RPKICounts{}, RPKICounts{}
+		r.v4Invalid, r.v6Invalid = nil, nil
+		if r.vrps != nil {
+			track := func(prefix netip.Prefix, n *node) {
+				for id, attr := range n.paths {
+					r.rpkiTrack(Route{Prefix: prefix, PathID: id}, nil, attr)
+				}
+			}
+			r.walkIPv4(track)
+			r.walkIPv6(track)
+		}
+	}
+
+	for key, d := range r.v4Damp {
+		if d.suppressed {
+			r.setSuppressed(key, d, true)
+		}
+	}
+	for key, d := range r.v6Damp {
+		if d.suppressed {
+			r.setSuppressed(key, d, true)
+		}
+	}
+
+	next.resetUnlocked()
+	return diffs
+}
+
+// Refresh builds a replacement table with NewReplacement, lets fill insert
+// the full set of routes into it, and swaps it in with Replace, returning the
+// differences. r remains fully readable and writable while fill runs; writes
+// made to r meanwhile are discarded by the swap.
+func (r *Rib) Refresh(fill func(next *Rib)) []RouteDiff {
+	next := r.NewReplacement()
+	fill(&next)
+	return r.Replace(&next)
+}
+
+// keepTimestamps copies the timestamps of a's paths onto the same paths of b:
+// both times for unchanged paths and the first-seen time for changed ones.
+func keepTimestamps(a, b *node) {
+	for id, newAttr := range b.paths {
+		oldAttr := a.paths[id]
+		old := a.meta[id]
+		if oldAttr == nil || old == nil || (old.firstSeen.IsZero() && old.lastModified.IsZero()) {
+			continue
+		}
+		m := b.pathMeta(id, true)
+		m.firstSeen = old.firstSeen
+		if oldAttr.hash == newAttr.hash && equalAttributes(oldAttr, newAttr) {
+			m.lastModified = old.lastModified
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestRefresh(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	router := rib.GetNewRib()
+	router.SetTimestamps(&rib.TimestampConfig{Clock: func() time.Time { return now }})
+	router.SetImportPolicy(&rib.Policy{Terms: []rib.PolicyTerm{{Actions: []rib.PolicyAction{rib.SetLocalPref(150)}}}})
+
+	stable := netip.MustParsePrefix("1.1.1.0/24")
+	changed := netip.MustParsePrefix("8.8.8.0/24")
+	removed := netip.MustParsePrefix("9.9.9.0/24")
+	added := netip.MustParsePrefix("2606:4700::/32")
+	router.InsertIPv4(rib.Route{Prefix: stable, Attributes: &rib.RouteAttributes{AsPath: []uint32{13335}}})
+	router.InsertIPv4(rib.Route{Prefix: changed, Attributes: &rib.RouteAttributes{AsPath: []uint32{15169}}})
+	router.InsertIPv4(rib.Route{Prefix: removed, Attributes: &rib.RouteAttributes{AsPath: []uint32{19281}}})
+	before := router.Churn()
+
+	// Readers never see the stable prefix disappear while the refresh runs.
+	var missing atomic.Int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if router.LookupIPv4(stable) == nil {
+				missing.Add(1)
+			}
+		}
+	}()
+
+	now = now.Add(time.Hour)
+	diffs := router.Refresh(func(next *rib.Rib) {
+		next.InsertIPv4(rib.Route{Prefix: stable, Attributes: &rib.RouteAttributes{AsPath: []uint32{13335}}})
+		next.InsertIPv4(rib.Route{Prefix: changed, Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 15169}}})
+		next.InsertIPv6(rib.Route{Prefix: added, Attributes: &rib.RouteAttributes{AsPath: []uint32{13335}}})
+		if next.V4Count() != 2 || router.V4Count() != 3 {
+			t.Errorf("expected replacement built aside, got %d and %d prefixes", next.V4Count(), router.V4Count())
+		}
+	})
+	close(stop)
+	wg.Wait()
+	if missing.Load() != 0 {
+		t.Errorf("stable prefix missing in %d lookups", missing.Load())
+	}
+
+	want := []struct {
+		kind   rib.DiffKind
+		prefix netip.Prefix
+	}{
+		{rib.DiffChanged, changed},
+		{rib.DiffRemoved, removed},
+		{rib.DiffAdded, added},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("expected %d diffs, got %v", len(want), diffs)
+	}
+	for i, w := range want {
+		if diffs[i].Kind != w.kind || diffs[i].Prefix != w.prefix {
+			t.Errorf("diff %d: got %s %s, want %s %s", i, diffs[i].Kind, diffs[i].Prefix, w.kind, w.prefix)
+		}
+	}
+
+	if router.V4Count() != 2 || router.V6Count() != 1 || router.LookupIPv4(removed) != nil {
+		t.Errorf("unexpected table after refresh: %d/%d", router.V4Count(), router.V6Count())
+	}
+	// The replacement was filled through the same import policy.
+	if got := router.LookupIPv6(added); got.Attributes.LocalPref != 150 {
+		t.Errorf("expected policy applied to replacement, got %+v", got.Attributes)
+	}
+	start := now.Add(-time.Hour)
+	if got := router.LookupIPv4(stable); !got.FirstSeen.Equal(start) || !got.LastModified.Equal(start) {
+		t.Errorf("stable path: got %v %v", got.FirstSeen, got.LastModified)
+	}
+	if got := router.LookupIPv4(changed); !got.FirstSeen.Equal(start) || !got.LastModified.Equal(now) {
+		t.Errorf("changed path: got %v %v", got.FirstSeen, got.LastModified)
+	}
+	churn := router.Churn()
+	if churn.V4Updates-before.V4Updates != 1 || churn.V4Withdrawals-before.V4Withdrawals != 1 || churn.V6Updates-before.V6Updates != 1 {
+		t.Errorf("unexpected churn %+v after %+v", churn, before)
+	}
+
+	// Inserting and deleting after the swap works against the new table.
+	router.InsertIPv4(rib.Route{Prefix: removed})
+	if err := router.DeleteIPv4(changed, 0); err != nil || router.V4Count() != 2 {
+		t.Errorf("update after refresh: %v, %d prefixes", err, router.V4Count())
+	}
+}
+
+func TestReplaceRPKI(t *testing.T) {
+	vrps := rib.NewVRPSet()
+	vrps.Add(rib.VRP{Prefix: netip.MustParsePrefix("1.1.1.0/24"), MaxLength: 24, ASN: 13335})
+
+	router := rib.GetNewRib()
+	router.SetVRPs(vrps)
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24"), Attributes: &rib.RouteAttributes{AsPath: []uint32{13335}}})
+
+	// A replacement built without the VRP set is revalidated on swap.
+	next := rib.GetNewRib()
+	next.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24"), Attributes: &rib.RouteAttributes{AsPath: []uint32{64666}}})
+	next.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("9.9.9.0/24"), Attributes: &rib.RouteAttributes{AsPath: []uint32{19281}}})
+	diffs := router.Replace(&next)
+
+	if len(diffs) != 2 || next.V4Count() != 0 {
+		t.Errorf("expected 2 diffs and an emptied replacement, got %v and %d", diffs, next.V4Count())
+	}
+	if got := router.RPKIStats(); got != (rib.RPKICounts{Invalid: 1, NotFound: 1}) {
+		t.Errorf("unexpected RPKI counts %+v", got)
+	}
+	if router.Replace(&router) != nil {
+		t.Error("expected replacing a RIB with itself to do nothing")
+	}
+}
+
+func TestReplaceCopiedRib(t *testing.T) {
+	router := rib.GetNewRib()
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24")})
+
+	// A copy shares the RIB's locks, which must only be taken once.
+	done := make(chan []rib.RouteDiff)
+	go func() {
+		c := router
+		done <- router.Replace(&c)
+	}()
+	select {
+	case diffs := <-done:
+		if len(diffs) != 0 || router.V4Count() != 1 {
+			t.Errorf("expected an unchanged RIB, got %v and %d prefixes", diffs, router.V4Count())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Replace with a copy deadlocked")
+	}
+}
@@ -1,9 +1,9 @@
 package routing_table
 
 import (
-	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
 	"net/netip"
 	"regexp"
 	"strconv"
@@ -51,6 +51,9 @@ type Rib struct {
 	// attrTable deduplicates and reference-counts BGP route attributes
 	// across all prefixes, drastically reducing memory usage.
 	attrTable *attrTable
+	// peerOwned is set on the tables of a PeerRib, which share one attrTable
+	// and must not have it swapped out by Reset or Replace.
+	peerOwned bool
 
 	v4Count     int
 	v6Count     int
@@ -60,6 +63,49 @@ type Rib struct {
 	v6NodeCount uint64
 	v4masks     map[int]int
 	v6masks     map[int]int
+
+	// importFilter, when set, rejects routes that do not match it at insert time.
+	importFilter *Filter
+
+	// importPolicy, when set, may modify or reject routes before they are interned.
+	importPolicy *Policy
+
+	// conflictHook, when set, is called for origin conflicts created by inserts.
+	conflictHook func(OriginConflict)
+
+	// bogonFilter, when set, rejects bogon routes at insert time. Rejections
+	// are recorded per family, keyed by prefix and PathID.
+	bogonFilter *BogonList
+	v4Bogons    map[PrefixWithID]BogonRoute
+	v6Bogons    map[PrefixWithID]BogonRoute
+
+	// vrps, when set, is the VRP set the validation state of every path is
+	// tracked against. State counts and invalid paths are kept per family.
+	vrps      *VRPSet
+	v4RPKI    RPKICounts
+	v6RPKI    RPKICounts
+	v4Invalid map[PrefixWithID]struct{}
+	v6Invalid map[PrefixWithID]struct{}
+
+	// limits, when set, caps the prefixes and paths accepted per family.
+	limits  *Limits
+	v4Limit limitState
+	v6Limit limitState
+
+	// damping, when set, enables flap damping. Flap histories are kept per
+	// family, keyed by prefix and PathID, and outlive withdrawn paths.
+	damping *DampingConfig
+	v4Damp  map[PrefixWithID]*dampState
+	v6Damp  map[PrefixWithID]*dampState
+
+	// timestamps, when set, enables first-seen and last-modified times per path.
+	timestamps *TimestampConfig
+
+	// logger, when set, receives statistics, rejections and limit events
+	// instead of slog.Default. Churn is counted per family.
+	logger  *slog.Logger
+	v4Churn churnCounts
+	v6Churn churnCounts
 }
 
 // LargeCommunity represents a BGP Large Community (RFC 8092).
@@ -86,6 +132,15 @@ type Route struct {
 	Prefix     netip.Prefix
 	Attributes *RouteAttributes
 	PathID     uint32 // 0 for non-add-path routes
+
+	// FirstSeen is when the path was added and LastModified when its
+	// attributes last changed. Both are zero unless timestamps are enabled
+	// with SetTimestamps, and in results of address searches.
+	FirstSeen    time.Time
+	LastModified time.Time
+
+	// Stale is set for paths kept across a peer restart; see MarkStale.
+	Stale bool
 }
 
 // PrefixWithID is used for batch deletions in Add-Path sessions.
@@ -108,25 +163,134 @@ type node struct {
 	children [2]*node
 	paths    map[uint32]*RouteAttributes // pathID -> attrs; pathID 0 = non-add-path
 	parent   *node
+	meta     map[uint32]*pathMeta // optional per-path state; nil until a path needs it
+}
+
+// pathMeta holds per-path state beyond the attributes. It is only allocated
+// for paths that have some, so plain RIBs pay for one nil map per node.
+type pathMeta struct {
+	// suppressed hides the path from best path selection (flap damping).
+	suppressed bool
+
+	// stale marks a path kept across a peer restart until it is
+	// re-announced or swept (graceful restart).
+	stale bool
+
+	// firstSeen and lastModified are recorded when timestamps are enabled.
+	firstSeen    time.Time
+	lastModified time.Time
+}
+
+// isZero reports whether m holds no state and can be dropped.
+func (m *pathMeta) isZero() bool {
+	return !m.suppressed && !m.stale && m.firstSeen.IsZero() && m.lastModified.IsZero()
+}
+
+// pathMeta returns the state of pathID, allocating it if create is set.
+func (n *node) pathMeta(pathID uint32, create bool) *pathMeta {
+	if m := n.meta[pathID]; m != nil || !create {
+		return m
+	}
+	if n.meta == nil {
+		n.meta = make(map[uint32]*pathMeta)
+	}
+	m := &pathMeta{}
+	n.meta[pathID] = m
+	return m
+}
+
+// tidyMeta drops the state of pathID once it holds nothing.
+func (n *node) tidyMeta(pathID uint32) {
+	if m := n.meta[pathID]; m != nil && m.isZero() {
+		n.dropMeta(pathID)
+	}
+}
+
+// dropMeta discards the state of pathID when the path is removed.
+func (n *node) dropMeta(pathID uint32) {
+	delete(n.meta, pathID)
+	if len(n.meta) == 0 {
+		n.meta = nil
+	}
+}
+
+// stale reports whether pathID is marked stale.
+func (n *node) stale(pathID uint32) bool {
+	m := n.meta[pathID]
+	return m != nil && m.stale
+}
+
+// clearStale unmarks pathID, as it has been re-announced.
+func (n *node) clearStale(pathID uint32) {
+	if m := n.meta[pathID]; m != nil && m.stale {
+		m.stale = false
+		n.tidyMeta(pathID)
+	}
+}
+
+// suppressed reports whether pathID is hidden from best path selection.
+func (n *node) suppressed(pathID uint32) bool {
+	m := n.meta[pathID]
+	return m != nil && m.suppressed
+}
+
+// route returns pathID of n as a Route for prefix, with its timestamps.
+func (n *node) route(prefix netip.Prefix, pathID uint32) Route {
+	rt := Route{Prefix: prefix, Attributes: n.paths[pathID], PathID: pathID}
+	if m := n.meta[pathID]; m != nil {
+		rt.FirstSeen, rt.LastModified = m.firstSeen, m.lastModified
+		rt.Stale = m.stale
+	}
+	return rt
+}
+
+// bestRoute returns the best path of n as a Route for prefix, with its
+// timestamps but without its PathID, or nil if no path is usable.
+func (n *node) bestRoute(prefix netip.Prefix) *Route {
+	attr := n.bestPath()
+	if attr == nil {
+		return nil
+	}
+	rt := n.route(prefix, bestPathID(n, attr))
+	rt.PathID = 0
+	return &rt
 }
 
 // bestPath returns the "best" path from the node's paths map using deterministic rules.
+// Suppressed paths are skipped and stale paths only win when no fresh path is
+// usable; nil is returned if no path is usable.
 func (n *node) bestPath() *RouteAttributes {
 	if len(n.paths) == 0 {
 		return nil
 	}
 	var bestAttr *RouteAttributes
 	var bestPathID uint32
+	var bestStale bool
 	first := true
 
 	for pathID, attr := range n.paths {
+		if n.meta != nil && n.suppressed(pathID) {
+			continue
+		}
+		stale := n.meta != nil && n.stale(pathID)
 		if first {
 			bestAttr = attr
 			bestPathID = pathID
+			bestStale = stale
 			first = false
 			continue
 		}
 
+		// 0. Any fresh path beats a stale one (LLGR).
+		if stale != bestStale {
+			if !stale {
+				bestAttr = attr
+				bestPathID = pathID
+				bestStale = false
+			}
+			continue
+		}
+
 		// 1. Higher LocalPref
 		if attr.LocalPref > bestAttr.LocalPref {
 			bestAttr = attr
@@ -172,6 +336,14 @@ func SelectBest(routes []Route) *Route {
 			continue
 		}
 
+		// 0. Any fresh route beats a stale one (LLGR).
+		if curr.Stale != best.Stale {
+			if !curr.Stale {
+				best = curr
+			}
+			continue
+		}
+
 		// 1. Higher LocalPref
 		if curr.Attributes.LocalPref > best.Attributes.LocalPref {
 			best = curr
@@ -218,12 +390,22 @@ func GetNewRib() Rib {
 // Reset atomically flushes the entire routing table and resets all counters.
 // This is extremely fast as it only re-assigns the root arrays, allowing the GC
 // to clean up the abandoned trie nodes. It also clears the attribute table.
+// It panics on the Adj-RIB-In or Loc-RIB of a PeerRib, whose routes must be
+// removed through the PeerRib.
 func (r *Rib) Reset() {
+	if r.peerOwned {
+		panic("Reset: table belongs to a PeerRib")
+	}
 	r.v4mu.Lock()
 	r.v6mu.Lock()
 	defer r.v4mu.Unlock()
 	defer r.v6mu.Unlock()
+	r.resetUnlocked()
+}
 
+// resetUnlocked empties r, keeping its configuration. The caller must hold
+// both locks.
+func (r *Rib) resetUnlocked() {
 	r.ipv4Root = [256]*node{}
 	r.ipv6Root = [32]*node{}
 	r.v4Count = 0
@@ -235,6 +417,82 @@ func (r *Rib) Reset() {
 	r.v4masks = make(map[int]int)
 	r.v6masks = make(map[int]int)
 	r.attrTable = newAttrTable()
+	r.v4Bogons = nil
+	r.v6Bogons = nil
+	r.v4RPKI = RPKICounts{}
+	r.v6RPKI = RPKICounts{}
+	r.v4Invalid = nil
+	r.v6Invalid = nil
+	r.v4Limit = limitState{}
+	r.v6Limit = limitState{}
+	r.v4Damp = nil
+	r.v6Damp = nil
+	r.v4Churn = churnCounts{}
+	r.v6Churn = churnCounts{}
+}
+
+// Clone returns a deep copy of the RIB that shares no state with r, for use
+// as a point-in-time snapshot. Attributes are re-interned into the copy's own
+// attribute table. Policy settings such as the import filter are copied too.
+func (r *Rib) Clone() Rib {
+	c := GetNewRib()
+
+	r.v4mu.RLock()
+	for i, n := range r.ipv4Root {
+		c.ipv4Root[i] = cloneNode(n, nil, c.attrTable)
+	}
+	c.v4Count, c.v4PathCount, c.v4NodeCount = r.v4Count, r.v4PathCount, r.v4NodeCount
+	for k, v := range r.v4masks {
+		c.v4masks[k] = v
+	}
+	c.importFilter = r.importFilter
+	c.importPolicy = r.importPolicy
+	c.bogonFilter = r.bogonFilter
+	c.vrps = r.vrps
+	c.limits = r.limits
+	c.damping = r.damping
+	c.timestamps = r.timestamps
+	c.logger = r.logger
+	c.v4Damp = cloneDampStates(r.v4Damp)
+	c.v4RPKI = r.v4RPKI
+	c.v4Invalid = maps.Clone(r.v4Invalid)
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	for i, n := range r.ipv6Root {
+		c.ipv6Root[i] = cloneNode(n, nil, c.attrTable)
+	}
+	c.v6Count, c.v6PathCount, c.v6NodeCount = r.v6Count, r.v6PathCount, r.v6NodeCount
+	for k, v := range r.v6masks {
+		c.v6masks[k] = v
+	}
+	c.v6RPKI = r.v6RPKI
+	c.v6Invalid = maps.Clone(r.v6Invalid)
+	c.v6Damp = cloneDampStates(r.v6Damp)
+	r.v6mu.RUnlock()
+
+	return c
+}
+
+// cloneNode deep copies a subtrie, interning its attributes into at.
+func cloneNode(n, parent *node, at *attrTable) *node {
+	if n == nil {
+		return nil
+	}
+	c := &node{
+		paths:  make(map[uint32]*RouteAttributes, len(n.paths)),
+		parent: parent,
+	}
+	for id, attr := range n.paths {
+		c.paths[id] = at.getOrInsert(attr)
+	}
+	for id, m := range n.meta {
+		c.pathMeta(id, true)
+		*c.meta[id] = *m
+	}
+	c.children[0] = cloneNode(n.children[0], c, at)
+	c.children[1] = cloneNode(n.children[1], c, at)
+	return c
 }
 
 func (r *router) Size() int {
@@ -332,39 +590,98 @@ func (r *Rib) GetSubnets() (map[int]int, map[int]int) {
 }
 
 // InsertIPv4 adds an IPv4 route to the RIB, or updates its attributes if it already exists.
-func (r *Rib) InsertIPv4(route Route) {
+// A refused route returns a *RouteError wrapping the reason, such as ErrBadMask.
+func (r *Rib) InsertIPv4(route Route) error {
 	if route.Prefix.Addr().Is6() {
-		return
+		err := insertError(route, ErrWrongFamily)
+		r.v4mu.RLock()
+		l := r.log()
+		r.v4mu.RUnlock()
+		logRejected(l, err)
+		return err
 	}
 	r.v4mu.Lock()
-	defer r.v4mu.Unlock()
-	r.insertIPv4Unlocked(route)
+	_, err := r.insertIPv4Unlocked(route)
+	l := r.log()
+	r.v4mu.Unlock()
+	logRejected(l, err)
+	return err
 }
 
 // InsertIPv4Batch adds multiple IPv4 routes to the RIB, acquiring the lock only once.
 // Returns a slice of prefixes that were newly added to the RIB (went from 0 to 1 paths).
 func (r *Rib) InsertIPv4Batch(routes []Route) []netip.Prefix {
 	r.v4mu.Lock()
-	defer r.v4mu.Unlock()
-
 	var newPrefixes []netip.Prefix
+	var rejected []error
 	for _, rt := range routes {
 		if rt.Prefix.Addr().Is4() {
-			if r.insertIPv4Unlocked(rt) {
+			isNew, err := r.insertIPv4Unlocked(rt)
+			if isNew {
 				newPrefixes = append(newPrefixes, rt.Prefix)
 			}
+			if err != nil {
+				rejected = append(rejected, err)
+			}
 		}
 	}
+	l := r.log()
+	r.v4mu.Unlock()
+
+	for _, err := range rejected {
+		logRejected(l, err)
+	}
 	return newPrefixes
 }
 
-func (r *Rib) insertIPv4Unlocked(route Route) bool {
+// InsertIPv4BatchResults is InsertIPv4Batch reporting the outcome of every
+// route: errs[i] is nil if routes[i] was stored, or the reason it was refused.
+// Unlike InsertIPv4Batch, IPv6 routes are reported with ErrWrongFamily.
+func (r *Rib) InsertIPv4BatchResults(routes []Route) []error {
+	r.v4mu.Lock()
+	errs := make([]error, len(routes))
+	for i, rt := range routes {
+		if rt.Prefix.Addr().Is6() {
+			errs[i] = insertError(rt, ErrWrongFamily)
+		} else {
+			_, errs[i] = r.insertIPv4Unlocked(rt)
+		}
+	}
+	l := r.log()
+	r.v4mu.Unlock()
+
+	for _, err := range errs {
+		logRejected(l, err)
+	}
+	return errs
+}
+
+func (r *Rib) insertIPv4Unlocked(route Route) (bool, error) {
 	mask := route.Prefix.Bits()
 
 	// Guard: no internet IPv4 prefix is shorter than /8 or longer than /24.
-	if mask < 8 || mask > 24 {
-		log.Printf("rejecting IPv4 prefix %s: mask /%d is outside allowed range /8–/24", route.Prefix, mask)
-		return false
+	if err := checkIPv4(route.Prefix); err != nil {
+		return false, insertError(route, err)
+	}
+
+	// Import policy: a rejected update withdraws any previously accepted path.
+	if r.bogonRejected(route) {
+		r.deleteIPv4Unlocked(route.Prefix, route.PathID)
+		return false, insertError(route, ErrBogon)
+	}
+	if r.importRejected(route) {
+		r.deleteIPv4Unlocked(route.Prefix, route.PathID)
+		return false, insertError(route, ErrFiltered)
+	}
+	var accepted bool
+	if route, accepted = r.applyImportPolicy(route); !accepted {
+		r.deleteIPv4Unlocked(route.Prefix, route.PathID)
+		return false, insertError(route, ErrPolicyRejected)
+	}
+
+	// Limits: a rejected insert leaves the RIB unchanged.
+	if r.limitRejected(route) {
+		return false, insertError(route, ErrLimitExceeded)
 	}
 
 	addr := route.Prefix.Addr().As4()
@@ -389,13 +706,15 @@ func (r *Rib) insertIPv4Unlocked(route Route) bool {
 			r.v4masks[mask]++
 			isNew = true
 		}
-		if oldAttr, ok := currentNode.paths[route.PathID]; ok {
+		oldAttr, ok := currentNode.paths[route.PathID]
+		if ok {
 			r.attrTable.release(oldAttr)
 		} else {
 			r.v4PathCount++
 		}
 		currentNode.paths[route.PathID] = dedupAttr
-		return isNew
+		r.stored(currentNode, route, oldAttr, dedupAttr)
+		return isNew, nil
 	}
 
 	// Walk bits 9–24 through octets 1 and 2.
@@ -418,61 +737,143 @@ func (r *Rib) insertIPv4Unlocked(route Route) bool {
 					r.v4masks[mask]++
 					isNew = true
 				}
-				if oldAttr, ok := currentNode.paths[route.PathID]; ok {
+				oldAttr, ok := currentNode.paths[route.PathID]
+				if ok {
 					r.attrTable.release(oldAttr)
 				} else {
 					r.v4PathCount++
 				}
 				currentNode.paths[route.PathID] = dedupAttr
-				return isNew
+				r.stored(currentNode, route, oldAttr, dedupAttr)
+				return isNew, nil
 			}
 			bitCount++
 		}
 	}
-	return false
+	return false, nil
+}
+
+// stored runs the per-path bookkeeping after an insert stored newAttr for
+// route on n, replacing oldAttr (nil for a new path). The caller must hold the
+// lock of the family.
+func (r *Rib) stored(n *node, route Route, oldAttr, newAttr *RouteAttributes) {
+	if n.meta != nil {
+		n.clearStale(route.PathID)
+	}
+	if r.vrps != nil {
+		r.rpkiTrack(route, oldAttr, newAttr)
+	}
+	if r.damping != nil {
+		r.dampUpdate(route, oldAttr, newAttr)
+	}
+	if r.timestamps != nil {
+		r.stamp(n, route.PathID, oldAttr, newAttr)
+	}
+	if r.conflictHook != nil {
+		r.flagConflicts(route.Prefix.Masked(), n, route.PathID, oldAttr)
+	}
+	if route.Prefix.Addr().Is4() {
+		r.v4Churn.updates++
+	} else {
+		r.v6Churn.updates++
+	}
 }
 
 // InsertIPv6 adds an IPv6 route to the RIB, or updates its attributes if it already exists.
-func (r *Rib) InsertIPv6(route Route) {
+// A refused route returns a *RouteError wrapping the reason, such as ErrBadMask.
+func (r *Rib) InsertIPv6(route Route) error {
 	if route.Prefix.Addr().Is4() {
-		return
+		err := insertError(route, ErrWrongFamily)
+		r.v6mu.RLock()
+		l := r.log()
+		r.v6mu.RUnlock()
+		logRejected(l, err)
+		return err
 	}
 	r.v6mu.Lock()
-	defer r.v6mu.Unlock()
-	r.insertIPv6Unlocked(route)
+	_, err := r.insertIPv6Unlocked(route)
+	l := r.log()
+	r.v6mu.Unlock()
+	logRejected(l, err)
+	return err
 }
 
 // InsertIPv6Batch adds multiple IPv6 routes to the RIB, acquiring the lock only once.
 // Returns a slice of prefixes that were newly added to the RIB (went from 0 to 1 paths).
 func (r *Rib) InsertIPv6Batch(routes []Route) []netip.Prefix {
 	r.v6mu.Lock()
-	defer r.v6mu.Unlock()
-
 	var newPrefixes []netip.Prefix
+	var rejected []error
 	for _, rt := range routes {
 		if rt.Prefix.Addr().Is6() {
-			if r.insertIPv6Unlocked(rt) {
+			isNew, err := r.insertIPv6Unlocked(rt)
+			if isNew {
 				newPrefixes = append(newPrefixes, rt.Prefix)
 			}
+			if err != nil {
+				rejected = append(rejected, err)
+			}
 		}
 	}
+	l := r.log()
+	r.v6mu.Unlock()
+
+	for _, err := range rejected {
+		logRejected(l, err)
+	}
 	return newPrefixes
 }
 
-func (r *Rib) insertIPv6Unlocked(route Route) bool {
+// InsertIPv6BatchResults is InsertIPv6Batch reporting the outcome of every
+// route: errs[i] is nil if routes[i] was stored, or the reason it was refused.
+// Unlike InsertIPv6Batch, IPv4 routes are reported with ErrWrongFamily.
+func (r *Rib) InsertIPv6BatchResults(routes []Route) []error {
+	r.v6mu.Lock()
+	errs := make([]error, len(routes))
+	for i, rt := range routes {
+		if rt.Prefix.Addr().Is4() {
+			errs[i] = insertError(rt, ErrWrongFamily)
+		} else {
+			_, errs[i] = r.insertIPv6Unlocked(rt)
+		}
+	}
+	l := r.log()
+	r.v6mu.Unlock()
+
+	for _, err := range errs {
+		logRejected(l, err)
+	}
+	return errs
+}
+
+func (r *Rib) insertIPv6Unlocked(route Route) (bool, error) {
 	addr := route.Prefix.Addr().As16()
 	mask := route.Prefix.Bits()
 
-	// Guard: all internet IPv6 prefixes must be within 2000::/3.
-	if addr[0] < 0x20 || addr[0] > 0x3F {
-		log.Printf("rejecting IPv6 prefix %s: not within 2000::/3", route.Prefix)
-		return false
+	// Guard: all internet IPv6 prefixes must be within 2000::/3, and none is
+	// shorter than /8 or longer than /48.
+	if err := checkIPv6(route.Prefix); err != nil {
+		return false, insertError(route, err)
+	}
+
+	// Import policy: a rejected update withdraws any previously accepted path.
+	if r.bogonRejected(route) {
+		r.deleteIPv6Unlocked(route.Prefix, route.PathID)
+		return false, insertError(route, ErrBogon)
+	}
+	if r.importRejected(route) {
+		r.deleteIPv6Unlocked(route.Prefix, route.PathID)
+		return false, insertError(route, ErrFiltered)
+	}
+	var accepted bool
+	if route, accepted = r.applyImportPolicy(route); !accepted {
+		r.deleteIPv6Unlocked(route.Prefix, route.PathID)
+		return false, insertError(route, ErrPolicyRejected)
 	}
 
-	// Guard: no internet IPv6 prefix is shorter than /8 or longer than /48.
-	if mask < 8 || mask > 48 {
-		log.Printf("rejecting IPv6 prefix %s: mask /%d is outside allowed range /8–/48", route.Prefix, mask)
-		return false
+	// Limits: a rejected insert leaves the RIB unchanged.
+	if r.limitRejected(route) {
+		return false, insertError(route, ErrLimitExceeded)
 	}
 
 	// Retrieve or create the deduplicated attributes
@@ -496,13 +897,15 @@ func (r *Rib) insertIPv6Unlocked(route Route) bool {
 			r.v6masks[mask]++
 			isNew = true
 		}
-		if oldAttr, ok := currentNode.paths[route.PathID]; ok {
+		oldAttr, ok := currentNode.paths[route.PathID]
+		if ok {
 			r.attrTable.release(oldAttr)
 		} else {
 			r.v6PathCount++
 		}
 		currentNode.paths[route.PathID] = dedupAttr
-		return isNew
+		r.stored(currentNode, route, oldAttr, dedupAttr)
+		return isNew, nil
 	}
 
 	// Walk bits 9–48 through octets 1–5.
@@ -525,28 +928,32 @@ func (r *Rib) insertIPv6Unlocked(route Route) bool {
 					r.v6masks[mask]++
 					isNew = true
 				}
-				if oldAttr, ok := currentNode.paths[route.PathID]; ok {
+				oldAttr, ok := currentNode.paths[route.PathID]
+				if ok {
 					r.attrTable.release(oldAttr)
 				} else {
 					r.v6PathCount++
 				}
 				currentNode.paths[route.PathID] = dedupAttr
-				return isNew
+				r.stored(currentNode, route, oldAttr, dedupAttr)
+				return isNew, nil
 			}
 			bitCount++
 		}
 	}
-	return false
+	return false, nil
 }
 
 // DeleteIPv4 removes a specific path for an IPv4 prefix from the RIB.
-func (r *Rib) DeleteIPv4(prefix netip.Prefix, pathID uint32) {
+// It returns a *RouteError wrapping ErrNotFound if the path is not in the RIB.
+func (r *Rib) DeleteIPv4(prefix netip.Prefix, pathID uint32) error {
 	if prefix.Addr().Is6() {
-		return
+		return deleteError(prefix, pathID, ErrWrongFamily)
 	}
 	r.v4mu.Lock()
 	defer r.v4mu.Unlock()
-	r.deleteIPv4Unlocked(prefix, pathID)
+	_, err := r.deleteIPv4Unlocked(prefix, pathID)
+	return err
 }
 
 // DeleteIPv4Batch removes multiple IPv4 paths from the RIB, acquiring the lock only once.
@@ -558,7 +965,7 @@ func (r *Rib) DeleteIPv4Batch(prefixes []PrefixWithID) []netip.Prefix {
 	var removedPrefixes []netip.Prefix
 	for _, p := range prefixes {
 		if p.Prefix.Addr().Is4() {
-			if r.deleteIPv4Unlocked(p.Prefix, p.PathID) {
+			if removed, _ := r.deleteIPv4Unlocked(p.Prefix, p.PathID); removed {
 				removedPrefixes = append(removedPrefixes, p.Prefix)
 			}
 		}
@@ -566,16 +973,34 @@ func (r *Rib) DeleteIPv4Batch(prefixes []PrefixWithID) []netip.Prefix {
 	return removedPrefixes
 }
 
-func (r *Rib) deleteIPv4Unlocked(prefix netip.Prefix, pathID uint32) bool {
+// DeleteIPv4BatchResults is DeleteIPv4Batch reporting the outcome of every
+// path: errs[i] is nil if prefixes[i] was removed, or the reason it was not.
+// Unlike DeleteIPv4Batch, IPv6 prefixes are reported with ErrWrongFamily.
+func (r *Rib) DeleteIPv4BatchResults(prefixes []PrefixWithID) []error {
+	r.v4mu.Lock()
+	defer r.v4mu.Unlock()
+
+	errs := make([]error, len(prefixes))
+	for i, p := range prefixes {
+		if p.Prefix.Addr().Is6() {
+			errs[i] = deleteError(p.Prefix, p.PathID, ErrWrongFamily)
+			continue
+		}
+		_, errs[i] = r.deleteIPv4Unlocked(p.Prefix, p.PathID)
+	}
+	return errs
+}
+
+func (r *Rib) deleteIPv4Unlocked(prefix netip.Prefix, pathID uint32) (bool, error) {
 	mask := prefix.Bits()
-	if mask < 8 || mask > 24 {
-		return false
+	if err := checkIPv4(prefix); err != nil {
+		return false, deleteError(prefix, pathID, err)
 	}
 
 	addr := prefix.Addr().As4()
 
 	if r.ipv4Root[addr[0]] == nil {
-		return false
+		return false, deleteError(prefix, pathID, ErrNotFound)
 	}
 	currentNode := r.ipv4Root[addr[0]]
 
@@ -583,10 +1008,11 @@ func (r *Rib) deleteIPv4Unlocked(prefix netip.Prefix, pathID uint32) bool {
 	if mask == 8 {
 		attr, ok := currentNode.paths[pathID]
 		if !ok {
-			return false
+			return false, deleteError(prefix, pathID, ErrNotFound)
 		}
 		r.attrTable.release(attr)
 		delete(currentNode.paths, pathID)
+		r.removed(currentNode, prefix, pathID, attr)
 		r.v4PathCount--
 
 		isRemoved := false
@@ -600,7 +1026,7 @@ func (r *Rib) deleteIPv4Unlocked(prefix netip.Prefix, pathID uint32) bool {
 				r.v4NodeCount--
 			}
 		}
-		return isRemoved
+		return isRemoved, nil
 	}
 
 	// Walk bits 9–24 to find the node holding this prefix.
@@ -610,16 +1036,17 @@ func (r *Rib) deleteIPv4Unlocked(prefix netip.Prefix, pathID uint32) bool {
 		for _, bit := range bits {
 			// If the path doesn't exist, the prefix was never inserted.
 			if currentNode.children[bit] == nil {
-				return false
+				return false, deleteError(prefix, pathID, ErrNotFound)
 			}
 			currentNode = currentNode.children[bit]
 			if bitCount == mask {
 				attr, ok := currentNode.paths[pathID]
 				if !ok {
-					return false
+					return false, deleteError(prefix, pathID, ErrNotFound)
 				}
 				r.attrTable.release(attr)
 				delete(currentNode.paths, pathID)
+				r.removed(currentNode, prefix, pathID, attr)
 				r.v4PathCount--
 
 				isRemoved := false
@@ -636,22 +1063,41 @@ func (r *Rib) deleteIPv4Unlocked(prefix netip.Prefix, pathID uint32) bool {
 						r.v4NodeCount--
 					}
 				}
-				return isRemoved
+				return isRemoved, nil
 			}
 			bitCount++
 		}
 	}
-	return false
+	return false, deleteError(prefix, pathID, ErrNotFound)
+}
+
+// removed runs the per-path bookkeeping after a delete removed pathID, whose
+// attributes were attr, from n. The caller must hold the lock of the family.
+func (r *Rib) removed(n *node, prefix netip.Prefix, pathID uint32, attr *RouteAttributes) {
+	n.dropMeta(pathID)
+	if r.vrps != nil {
+		r.rpkiTrack(Route{Prefix: prefix, PathID: pathID}, attr, nil)
+	}
+	if r.damping != nil {
+		r.dampWithdraw(prefix, pathID)
+	}
+	if prefix.Addr().Is4() {
+		r.v4Churn.withdrawals++
+	} else {
+		r.v6Churn.withdrawals++
+	}
 }
 
 // DeleteIPv6 removes a specific path for an IPv6 prefix from the RIB.
-func (r *Rib) DeleteIPv6(prefix netip.Prefix, pathID uint32) {
+// It returns a *RouteError wrapping ErrNotFound if the path is not in the RIB.
+func (r *Rib) DeleteIPv6(prefix netip.Prefix, pathID uint32) error {
 	if prefix.Addr().Is4() {
-		return
+		return deleteError(prefix, pathID, ErrWrongFamily)
 	}
 	r.v6mu.Lock()
 	defer r.v6mu.Unlock()
-	r.deleteIPv6Unlocked(prefix, pathID)
+	_, err := r.deleteIPv6Unlocked(prefix, pathID)
+	return err
 }
 
 // DeleteIPv6Batch removes multiple IPv6 paths from the RIB, acquiring the lock only once.
@@ -663,7 +1109,7 @@ func (r *Rib) DeleteIPv6Batch(prefixes []PrefixWithID) []netip.Prefix {
 	var removedPrefixes []netip.Prefix
 	for _, p := range prefixes {
 		if p.Prefix.Addr().Is6() {
-			if r.deleteIPv6Unlocked(p.Prefix, p.PathID) {
+			if removed, _ := r.deleteIPv6Unlocked(p.Prefix, p.PathID); removed {
 				removedPrefixes = append(removedPrefixes, p.Prefix)
 			}
 		}
@@ -671,17 +1117,35 @@ func (r *Rib) DeleteIPv6Batch(prefixes []PrefixWithID) []netip.Prefix {
 	return removedPrefixes
 }
 
-func (r *Rib) deleteIPv6Unlocked(prefix netip.Prefix, pathID uint32) bool {
+// DeleteIPv6BatchResults is DeleteIPv6Batch reporting the outcome of every
+// path: errs[i] is nil if prefixes[i] was removed, or the reason it was not.
+// Unlike DeleteIPv6Batch, IPv4 prefixes are reported with ErrWrongFamily.
+func (r *Rib) DeleteIPv6BatchResults(prefixes []PrefixWithID) []error {
+	r.v6mu.Lock()
+	defer r.v6mu.Unlock()
+
+	errs := make([]error, len(prefixes))
+	for i, p := range prefixes {
+		if p.Prefix.Addr().Is4() {
+			errs[i] = deleteError(p.Prefix, p.PathID, ErrWrongFamily)
+			continue
+		}
+		_, errs[i] = r.deleteIPv6Unlocked(p.Prefix, p.PathID)
+	}
+	return errs
+}
+
+func (r *Rib) deleteIPv6Unlocked(prefix netip.Prefix, pathID uint32) (bool, error) {
 	addr := prefix.Addr().As16()
 	mask := prefix.Bits()
 
-	if mask < 8 || mask > 48 || addr[0] < 0x20 || addr[0] > 0x3F {
-		return false
+	if err := checkIPv6(prefix); err != nil {
+		return false, deleteError(prefix, pathID, err)
 	}
 
 	idx := addr[0] - 0x20
 	if r.ipv6Root[idx] == nil {
-		return false
+		return false, deleteError(prefix, pathID, ErrNotFound)
 	}
 	currentNode := r.ipv6Root[idx]
 
@@ -689,10 +1153,11 @@ func (r *Rib) deleteIPv6Unlocked(prefix netip.Prefix, pathID uint32) bool {
 	if mask == 8 {
 		attr, ok := currentNode.paths[pathID]
 		if !ok {
-			return false
+			return false, deleteError(prefix, pathID, ErrNotFound)
 		}
 		r.attrTable.release(attr)
 		delete(currentNode.paths, pathID)
+		r.removed(currentNode, prefix, pathID, attr)
 		r.v6PathCount--
 
 		isRemoved := false
@@ -705,7 +1170,7 @@ func (r *Rib) deleteIPv6Unlocked(prefix netip.Prefix, pathID uint32) bool {
 				r.v6NodeCount--
 			}
 		}
-		return isRemoved
+		return isRemoved, nil
 	}
 
 	// Walk bits 9–48 to find the node holding this prefix.
@@ -714,16 +1179,17 @@ func (r *Rib) deleteIPv6Unlocked(prefix netip.Prefix, pathID uint32) bool {
 		bits := intToBinBitwise(addr[i])
 		for _, bit := range bits {
 			if currentNode.children[bit] == nil {
-				return false
+				return false, deleteError(prefix, pathID, ErrNotFound)
 			}
 			currentNode = currentNode.children[bit]
 			if bitCount == mask {
 				attr, ok := currentNode.paths[pathID]
 				if !ok {
-					return false
+					return false, deleteError(prefix, pathID, ErrNotFound)
 				}
 				r.attrTable.release(attr)
 				delete(currentNode.paths, pathID)
+				r.removed(currentNode, prefix, pathID, attr)
 				r.v6PathCount--
 
 				isRemoved := false
@@ -738,12 +1204,12 @@ func (r *Rib) deleteIPv6Unlocked(prefix netip.Prefix, pathID uint32) bool {
 						r.v6NodeCount--
 					}
 				}
-				return isRemoved
+				return isRemoved, nil
 			}
 			bitCount++
 		}
 	}
-	return false
+	return false, deleteError(prefix, pathID, ErrNotFound)
 }
 
 // deleteNode recursively prunes empty leaf nodes upward through the trie.
@@ -782,15 +1248,27 @@ func (r *Rib) SearchIPv4(ip netip.Addr) *Route {
 	r.v4mu.RLock()
 	defer r.v4mu.RUnlock()
 
+	if attr, bits := r.searchIPv4Unlocked(ip); attr != nil {
+		return &Route{
+			Prefix:     netip.PrefixFrom(ip, bits).Masked(),
+			Attributes: attr,
+		}
+	}
+	return nil
+}
+
+// searchIPv4Unlocked returns the best path and mask length of the longest
+// matching IPv4 prefix, or nil if none matches. The caller must hold v4mu.
+func (r *Rib) searchIPv4Unlocked(ip netip.Addr) (*RouteAttributes, int) {
 	var lpmAttr *RouteAttributes
 	var lpmLen int
 	addr := ip.As4()
 
 	// Look up the array entry for the first octet.
-	if r.ipv4Root[addr[0]] == nil {
-		return nil
-	}
 	currentNode := r.ipv4Root[addr[0]]
+	if currentNode == nil {
+		return nil, 0
+	}
 
 	// Check for a /8 match at the array entry node.
 	if attr := currentNode.bestPath(); attr != nil {
@@ -799,31 +1277,18 @@ func (r *Rib) SearchIPv4(ip netip.Addr) *Route {
 	}
 
 	// Walk bits 9–24, updating LPM at each node that holds a route.
-	// Uses a labeled break so that hitting a nil child exits both loops.
-	bitCount := 9
-v4walk:
-	for i := 1; i < 3; i++ {
-		bits := intToBinBitwise(addr[i])
-		for _, bit := range bits {
-			if currentNode.children[bit] != nil {
-				currentNode = currentNode.children[bit]
-				if attr := currentNode.bestPath(); attr != nil {
-					lpmAttr = attr
-					lpmLen = bitCount
-				}
-			} else {
-				break v4walk
-			}
-			bitCount++
+	for bitCount := 9; bitCount <= 24; bitCount++ {
+		octet := addr[(bitCount-1)/8]
+		bit := (octet >> (7 - uint((bitCount-1)%8))) & 1
+		if currentNode = currentNode.children[bit]; currentNode == nil {
+			break
 		}
-	}
-	if lpmAttr != nil {
-		return &Route{
-			Prefix:     netip.PrefixFrom(ip, lpmLen).Masked(),
-			Attributes: lpmAttr,
+		if attr := currentNode.bestPath(); attr != nil {
+			lpmAttr = attr
+			lpmLen = bitCount
 		}
 	}
-	return nil
+	return lpmAttr, lpmLen
 }
 
 // AllPathsSearchIPv4 performs a longest prefix match (LPM) lookup for an IPv4 address
@@ -884,20 +1349,31 @@ func (r *Rib) SearchIPv6(ip netip.Addr) *Route {
 	r.v6mu.RLock()
 	defer r.v6mu.RUnlock()
 
+	if attr, bits := r.searchIPv6Unlocked(ip); attr != nil {
+		return &Route{
+			Prefix:     netip.PrefixFrom(ip, bits).Masked(),
+			Attributes: attr,
+		}
+	}
+	return nil
+}
+
+// searchIPv6Unlocked returns the best path and mask length of the longest
+// matching IPv6 prefix, or nil if none matches. The caller must hold v6mu.
+func (r *Rib) searchIPv6Unlocked(ip netip.Addr) (*RouteAttributes, int) {
 	var lpmAttr *RouteAttributes
 	var lpmLen int
 	addr := ip.As16()
 
 	// Only addresses in 2000::/3 (first byte 0x20–0x3F) are supported.
 	if addr[0] < 0x20 || addr[0] > 0x3F {
-		return nil
+		return nil, 0
 	}
 
-	idx := addr[0] - 0x20
-	if r.ipv6Root[idx] == nil {
-		return nil
+	currentNode := r.ipv6Root[addr[0]-0x20]
+	if currentNode == nil {
+		return nil, 0
 	}
-	currentNode := r.ipv6Root[idx]
 
 	// Check for a match at the array entry node (e.g., a /8 route).
 	if attr := currentNode.bestPath(); attr != nil {
@@ -906,30 +1382,18 @@ func (r *Rib) SearchIPv6(ip netip.Addr) *Route {
 	}
 
 	// Walk bits 9–48, updating LPM at each node that holds a route.
-	bitCount := 9
-v6walk:
-	for i := 1; i < 6; i++ {
-		bits := intToBinBitwise(addr[i])
-		for _, bit := range bits {
-			if currentNode.children[bit] != nil {
-				currentNode = currentNode.children[bit]
-				if attr := currentNode.bestPath(); attr != nil {
-					lpmAttr = attr
-					lpmLen = bitCount
-				}
-			} else {
-				break v6walk
-			}
-			bitCount++
+	for bitCount := 9; bitCount <= 48; bitCount++ {
+		octet := addr[(bitCount-1)/8]
+		bit := (octet >> (7 - uint((bitCount-1)%8))) & 1
+		if currentNode = currentNode.children[bit]; currentNode == nil {
+			break
 		}
-	}
-	if lpmAttr != nil {
-		return &Route{
-			Prefix:     netip.PrefixFrom(ip, lpmLen).Masked(),
-			Attributes: lpmAttr,
+		if attr := currentNode.bestPath(); attr != nil {
+			lpmAttr = attr
+			lpmLen = bitCount
 		}
 	}
-	return nil
+	return lpmAttr, lpmLen
 }
 
 // AllPathsSearchIPv6 performs a longest prefix match (LPM) lookup for an IPv6 address
@@ -1006,13 +1470,7 @@ func (r *Rib) LookupIPv4(prefix netip.Prefix) *Route {
 
 	// A /8 prefix is stored directly on the array entry node.
 	if mask == 8 {
-		if attr := currentNode.bestPath(); attr != nil {
-			return &Route{
-				Prefix:     prefix.Masked(),
-				Attributes: attr,
-			}
-		}
-		return nil
+		return currentNode.bestRoute(prefix.Masked())
 	}
 
 	// Walk bits 9–24 to the exact depth.
@@ -1025,13 +1483,7 @@ func (r *Rib) LookupIPv4(prefix netip.Prefix) *Route {
 			}
 			currentNode = currentNode.children[bit]
 			if bitCount == mask {
-				if attr := currentNode.bestPath(); attr != nil {
-					return &Route{
-						Prefix:     prefix.Masked(),
-						Attributes: attr,
-					}
-				}
-				return nil
+				return currentNode.bestRoute(prefix.Masked())
 			}
 			bitCount++
 		}
@@ -1066,13 +1518,7 @@ func (r *Rib) LookupIPv6(prefix netip.Prefix) *Route {
 
 	// A /8 prefix is stored directly on the array entry node.
 	if mask == 8 {
-		if attr := currentNode.bestPath(); attr != nil {
-			return &Route{
-				Prefix:     prefix.Masked(),
-				Attributes: attr,
-			}
-		}
-		return nil
+		return currentNode.bestRoute(prefix.Masked())
 	}
 
 	// Walk bits 9–48 to the exact depth.
@@ -1085,13 +1531,7 @@ func (r *Rib) LookupIPv6(prefix netip.Prefix) *Route {
 			}
 			currentNode = currentNode.children[bit]
 			if bitCount == mask {
-				if attr := currentNode.bestPath(); attr != nil {
-					return &Route{
-						Prefix:     prefix.Masked(),
-						Attributes: attr,
-					}
-				}
-				return nil
+				return currentNode.bestRoute(prefix.Masked())
 			}
 			bitCount++
 		}
@@ -1187,12 +1627,8 @@ func nodeToRoutes(n *node, p netip.Prefix) []Route {
 		return nil
 	}
 	routes := make([]Route, 0, len(n.paths))
-	for id, attr := range n.paths {
-		routes = append(routes, Route{
-			Prefix:     p.Masked(),
-			Attributes: attr,
-			PathID:     id,
-		})
+	for id := range n.paths {
+		routes = append(routes, n.route(p.Masked(), id))
 	}
 	return routes
 }
@@ -1246,8 +1682,8 @@ func (r *Rib) MemoryUsage() MemoryStats {
 
 	attrCount, sliceBytes := r.attrTable.GetStats()
 
-	// Effective Routing Tables: nodes (32 bytes)
-	rtEffective := (v4nodes + v6nodes) * 32
+	// Effective Routing Tables: nodes (40 bytes)
+	rtEffective := (v4nodes + v6nodes) * 40
 	// Overhead Routing Tables: IPv4 Root Array (256 * 8) + IPv6 Root Array (32 * 8) = 2304 bytes
 	rtOverhead := uint64(2304)
 
@@ -1264,46 +1700,6 @@ func (r *Rib) MemoryUsage() MemoryStats {
 	}
 }
 
-// StartLogging spawns a background goroutine that logs the RIB statistics once per minute.
-// It logs the IPv4 and IPv6 route counts, prefix distributions (ignoring zero counts),
-// and the BIRD-formatted memory usage. The goroutine stops when the provided context is canceled.
-func (r *Rib) StartLogging(ctx context.Context) {
-	ticker := time.NewTicker(time.Minute)
-	go func() {
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				v4Count := r.V4Count()
-				v6Count := r.V6Count()
-				v4m, v6m := r.GetSubnets()
-
-				// Filter masks with 0 count
-				v4filtered := make(map[int]int)
-				for k, v := range v4m {
-					if v > 0 {
-						v4filtered[k] = v
-					}
-				}
-
-				v6filtered := make(map[int]int)
-				for k, v := range v6m {
-					if v > 0 {
-						v6filtered[k] = v
-					}
-				}
-
-				memUsage := r.MemoryUsage().String()
-
-				log.Printf("RIB Stats:\nIPv4 Routes: %d\nIPv6 Routes: %d\nIPv4 Distribution: %v\nIPv6 Distribution: %v\n%s",
-					v4Count, v6Count, v4filtered, v6filtered, memUsage)
-			}
-		}
-	}()
-}
-
 // PrefixesByOriginASN walks the entire RIB and returns all IPv4 and IPv6
 // routes whose origin ASN (last element in the AS path) matches the given ASN.
 func (r *Rib) PrefixesByOriginASN(asn uint32) (v4 []Route, v6 []Route) {
@@ -1339,12 +1735,7 @@ func collectByOriginV4(n *node, asn uint32, addr [4]byte, depth int, results *[]
 	for id, attr := range n.paths {
 		path := attr.AsPath
 		if len(path) > 0 && path[len(path)-1] == asn {
-			ip := netip.AddrFrom4(addr)
-			*results = append(*results, Route{
-				Prefix:     netip.PrefixFrom(ip, depth),
-				Attributes: attr,
-				PathID:     id,
-			})
+			*results = append(*results, n.route(netip.PrefixFrom(netip.AddrFrom4(addr), depth), id))
 		}
 	}
 
@@ -1372,12 +1763,7 @@ func collectByOriginV6(n *node, asn uint32, addr [16]byte, depth int, results *[
 	for id, attr := range n.paths {
 		path := attr.AsPath
 		if len(path) > 0 && path[len(path)-1] == asn {
-			ip := netip.AddrFrom16(addr)
-			*results = append(*results, Route{
-				Prefix:     netip.PrefixFrom(ip, depth),
-				Attributes: attr,
-				PathID:     id,
-			})
+			*results = append(*results, n.route(netip.PrefixFrom(netip.AddrFrom16(addr), depth), id))
 		}
 	}
 
@@ -1432,12 +1818,7 @@ func (r *Rib) PrefixesByAsPathRegex(re *regexp.Regexp) (v4 []Route, v6 []Route)
 func collectByAsPathRegexV4(n *node, re *regexp.Regexp, addr [4]byte, depth int, results *[]Route) {
 	for id, attr := range n.paths {
 		if re.MatchString(attr.ASPathString()) {
-			ip := netip.AddrFrom4(addr)
-			*results = append(*results, Route{
-				Prefix:     netip.PrefixFrom(ip, depth),
-				Attributes: attr,
-				PathID:     id,
-			})
+			*results = append(*results, n.route(netip.PrefixFrom(netip.AddrFrom4(addr), depth), id))
 		}
 	}
 
@@ -1463,12 +1844,7 @@ func collectByAsPathRegexV4(n *node, re *regexp.Regexp, addr [4]byte, depth int,
 func collectByAsPathRegexV6(n *node, re *regexp.Regexp, addr [16]byte, depth int, results *[]Route) {
 	for id, attr := range n.paths {
 		if re.MatchString(attr.ASPathString()) {
-			ip := netip.AddrFrom16(addr)
-			*results = append(*results, Route{
-				Prefix:     netip.PrefixFrom(ip, depth),
-				Attributes: attr,
-				PathID:     id,
-			})
+			*results = append(*results, n.route(netip.PrefixFrom(netip.AddrFrom16(addr), depth), id))
 		}
 	}
 
@@ -1573,6 +1949,78 @@ func collectPrefixesV6(n *node, addr [16]byte, depth int, results *[]netip.Prefi
 	}
 }
 
+// walkIPv4 calls fn for every IPv4 prefix holding at least one path, in address
+// order. The caller must hold v4mu.
+func (r *Rib) walkIPv4(fn func(prefix netip.Prefix, n *node)) {
+	for i := 0; i < 256; i++ {
+		if r.ipv4Root[i] != nil {
+			var addr [4]byte
+			addr[0] = byte(i)
+			walkNodesV4(r.ipv4Root[i], addr, 8, fn)
+		}
+	}
+}
+
+// walkIPv6 calls fn for every IPv6 prefix holding at least one path, in address
+// order. The caller must hold v6mu.
+func (r *Rib) walkIPv6(fn func(prefix netip.Prefix, n *node)) {
+	for i := 0; i < 32; i++ {
+		if r.ipv6Root[i] != nil {
+			var addr [16]byte
+			addr[0] = byte(i + 0x20)
+			walkNodesV6(r.ipv6Root[i], addr, 8, fn)
+		}
+	}
+}
+
+// walkNodesV4 recursively walks an IPv4 subtrie, reconstructing the prefix
+// address by setting bits as it descends.
+func walkNodesV4(n *node, addr [4]byte, depth int, fn func(netip.Prefix, *node)) {
+	if len(n.paths) > 0 {
+		fn(netip.PrefixFrom(netip.AddrFrom4(addr), depth), n)
+	}
+
+	if depth >= 24 {
+		return
+	}
+
+	for bit := 0; bit < 2; bit++ {
+		if n.children[bit] != nil {
+			nextAddr := addr
+			byteIdx := depth / 8
+			bitPos := uint(7 - (depth % 8))
+			if bit == 1 {
+				nextAddr[byteIdx] |= 1 << bitPos
+			}
+			walkNodesV4(n.children[bit], nextAddr, depth+1, fn)
+		}
+	}
+}
+
+// walkNodesV6 recursively walks an IPv6 subtrie, reconstructing the prefix
+// address by setting bits as it descends.
+func walkNodesV6(n *node, addr [16]byte, depth int, fn func(netip.Prefix, *node)) {
+	if len(n.paths) > 0 {
+		fn(netip.PrefixFrom(netip.AddrFrom16(addr), depth), n)
+	}
+
+	if depth >= 48 {
+		return
+	}
+
+	for bit := 0; bit < 2; bit++ {
+		if n.children[bit] != nil {
+			nextAddr := addr
+			byteIdx := depth / 8
+			bitPos := uint(7 - (depth % 8))
+			if bit == 1 {
+				nextAddr[byteIdx] |= 1 << bitPos
+			}
+			walkNodesV6(n.children[bit], nextAddr, depth+1, fn)
+		}
+	}
+}
+
 // ASPathString returns the AS path as a space-separated string.
 func (ra *RouteAttributes) ASPathString() string {
 	if len(ra.AsPath) == 0 {
@@ -0,0 +1,451 @@
+package routing_table
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RPKIState is the route origin validation state of a route (RFC 6811).
+type RPKIState uint8
+
+const (
+	// RPKINotFound means no VRP covers the route's prefix.
+	RPKINotFound RPKIState = iota
+	// RPKIValid means a covering VRP matches the route's origin and length.
+	RPKIValid
+	// RPKIInvalid means covering VRPs exist but none matches.
+	RPKIInvalid
+
+	// rpkiAbsent is the state of a path not in the RIB, used for tracking.
+	rpkiAbsent RPKIState = 0xff
+)
+
+func (s RPKIState) String() string {
+	switch s {
+	case RPKINotFound:
+		return "not-found"
+	case RPKIValid:
+		return "valid"
+	case RPKIInvalid:
+		return "invalid"
+	}
+	return fmt.Sprintf("RPKIState(%d)", uint8(s))
+}
+
+// VRP is a Validated ROA Payload: ASN may originate Prefix and any
+// more-specific of it up to MaxLength.
+type VRP struct {
+	Prefix    netip.Prefix
+	MaxLength int
+	ASN       uint32
+	TA        string
+}
+
+// VRPSet is a set of VRPs indexed in a per-family binary trie keyed on their
+// prefix, so validating a route walks at most one node per bit of its prefix.
+//
+// A set must not be modified once it is installed on a RIB with SetVRPs;
+// load a new set and install that instead.
+type VRPSet struct {
+	v4root *vrpNode
+	v6root *vrpNode
+	count  int
+}
+
+type vrpNode struct {
+	children [2]*vrpNode
+	vrps     []VRP
+}
+
+// NewVRPSet returns an empty VRP set.
+func NewVRPSet() *VRPSet {
+	return &VRPSet{}
+}
+
+// Add inserts a VRP. A zero MaxLength is taken as the prefix length.
+func (s *VRPSet) Add(v VRP) error {
+	if !v.Prefix.IsValid() {
+		return fmt.Errorf("invalid prefix")
+	}
+	v.Prefix = v.Prefix.Masked()
+	if v.MaxLength == 0 {
+		v.MaxLength = v.Prefix.Bits()
+	}
+	if v.MaxLength < v.Prefix.Bits() || v.MaxLength > v.Prefix.Addr().BitLen() {
+		return fmt.Errorf("invalid maxLength %d for %s", v.MaxLength, v.Prefix)
+	}
+
+	root := &s.v4root
+	if v.Prefix.Addr().Is6() {
+		root = &s.v6root
+	}
+	if *root == nil {
+		*root = &vrpNode{}
+	}
+	n := *root
+	addr := v.Prefix.Addr().AsSlice()
+	for i := 0; i < v.Prefix.Bits(); i++ {
+		bit := addrBit(addr, i)
+		if n.children[bit] == nil {
+			n.children[bit] = &vrpNode{}
+		}
+		n = n.children[bit]
+	}
+	n.vrps = append(n.vrps, v)
+	s.count++
+	return nil
+}
+
+// Len returns the number of VRPs in the set.
+func (s *VRPSet) Len() int {
+	return s.count
+}
+
+// VRPs returns every VRP in the set, IPv4 before IPv6, ordered by prefix.
+func (s *VRPSet) VRPs() []VRP {
+	vrps := make([]VRP, 0, s.count)
+	var collect func(n *vrpNode)
+	collect = func(n *vrpNode) {
+		if n == nil {
+			return
+		}
+		vrps = append(vrps, n.vrps...)
+		collect(n.children[0])
+		collect(n.children[1])
+	}
+	collect(s.v4root)
+	collect(s.v6root)
+	return vrps
+}
+
+// Validate returns the origin validation state of route, taking the last ASN
+// of its AS path as the origin. A route with an empty AS path has no origin
+// and can only be NotFound or Invalid.
+func (s *VRPSet) Validate(route Route) RPKIState {
+	origin, ok := pathOrigin(route.Attributes)
+	return s.validate(route.Prefix, origin, ok)
+}
+
+func (s *VRPSet) validate(prefix netip.Prefix, origin uint32, hasOrigin bool) RPKIState {
+	n := s.v4root
+	if prefix.Addr().Is6() {
+		n = s.v6root
+	}
+	prefix = prefix.Masked()
+	addr := prefix.Addr().AsSlice()
+
+	state := RPKINotFound
+	for depth := 0; n != nil; depth++ {
+		for _, v := range n.vrps {
+			// AS0 VRPs (RFC 6483) never validate anything.
+			if hasOrigin && v.ASN != 0 && v.ASN == origin && prefix.Bits() <= v.MaxLength {
+				return RPKIValid
+			}
+			state = RPKIInvalid
+		}
+		if depth == prefix.Bits() {
+			break
+		}
+		n = n.children[addrBit(addr, depth)]
+	}
+	return state
+}
+
+// vrpJSON is one entry of the "roas" array written by rpki-client
+// (numeric asn) and Routinator (asn as "AS65000").
+type vrpJSON struct {
+	ASN       json.RawMessage `json:"asn"`
+	Prefix    string          `json:"prefix"`
+	MaxLength int             `json:"maxLength"`
+	TA        string          `json:"ta"`
+}
+
+// LoadVRPs reads a VRP set from the JSON output of rpki-client (-j) or
+// Routinator (json or jsonext formats).
+func LoadVRPs(rd io.Reader) (*VRPSet, error) {
+	var file struct {
+		ROAs []vrpJSON `json:"roas"`
+	}
+	if err := json.NewDecoder(rd).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	s := NewVRPSet()
+	for i, roa := range file.ROAs {
+		asn, err := parseJSONASN(roa.ASN)
+		if err != nil {
+			return nil, fmt.Errorf("roa %d: %w", i, err)
+		}
+		prefix, err := netip.ParsePrefix(roa.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("roa %d: %w", i, err)
+		}
+		if err := s.Add(VRP{Prefix: prefix, MaxLength: roa.MaxLength, ASN: asn, TA: roa.TA}); err != nil {
+			return nil, fmt.Errorf("roa %d: %w", i, err)
+		}
+	}
+	return s, nil
+}
+
+// parseJSONASN parses an ASN given either as a JSON number or as a string
+// such as "AS65000".
+func parseJSONASN(raw json.RawMessage) (uint32, error) {
+	text := string(raw)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = strings.TrimPrefix(strings.ToUpper(unquoted), "AS")
+	}
+	asn, err := strconv.ParseUint(text, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid asn %s", raw)
+	}
+	return uint32(asn), nil
+}
+
+// RPKICounts holds the number of paths in each validation state.
+type RPKICounts struct {
+	Valid    int
+	Invalid  int
+	NotFound int
+}
+
+func (c *RPKICounts) add(state RPKIState, delta int) {
+	switch state {
+	case RPKIValid:
+		c.Valid += delta
+	case RPKIInvalid:
+		c.Invalid += delta
+	default:
+		c.NotFound += delta
+	}
+}
+
+// ValidateRPKI walks the entire RIB, validating every path against s, and
+// returns the invalid paths, IPv4 before IPv6 in address order, with the
+// number of paths in each state.
+func (r *Rib) ValidateRPKI(s *VRPSet) (invalid []Route, counts RPKICounts) {
+	check := func(prefix netip.Prefix, n *node) {
+		for _, rt := range sortedRoutes(n, prefix) {
+			state := s.Validate(rt)
+			counts.add(state, 1)
+			if state == RPKIInvalid {
+				invalid = append(invalid, rt)
+			}
+		}
+	}
+
+	r.v4mu.RLock()
+	r.walkIPv4(check)
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(check)
+	r.v6mu.RUnlock()
+
+	return invalid, counts
+}
+
+// SetVRPs installs s as the RIB's VRP set, after which the validation state
+// of every path is tracked as routes are inserted and deleted, and reported by
+// RPKIStats and RPKIInvalids. Replacing an installed set only re-evaluates
+// routes within the prefixes of VRPs that were added or removed. Passing nil
+// stops tracking.
+func (r *Rib) SetVRPs(s *VRPSet) {
+	r.v4mu.Lock()
+	r.v6mu.Lock()
+	defer r.v4mu.Unlock()
+	defer r.v6mu.Unlock()
+
+	old := r.vrps
+	r.vrps = s
+	switch {
+	case s == nil:
+		r.v4RPKI, r.v6RPKI = RPKICounts{}, RPKICounts{}
+		r.v4Invalid, r.v6Invalid = nil, nil
+	case old == nil:
+		r.v4RPKI, r.v6RPKI = RPKICounts{}, RPKICounts{}
+		r.v4Invalid, r.v6Invalid = nil, nil
+		track := func(prefix netip.Prefix, n *node) {
+			for id, attr := range n.paths {
+				r.rpkiTrack(Route{Prefix: prefix, PathID: id}, nil, attr)
+			}
+		}
+		r.walkIPv4(track)
+		r.walkIPv6(track)
+	default:
+		for _, p := range changedVRPPrefixes(old, s) {
+			r.walkUnder(p, func(prefix netip.Prefix, n *node) {
+				for id, attr := range n.paths {
+					origin, ok := pathOrigin(attr)
+					before := old.validate(prefix, origin, ok)
+					after := s.validate(prefix, origin, ok)
+					if before != after {
+						r.rpkiSet(PrefixWithID{Prefix: prefix, PathID: id}, before, after)
+					}
+				}
+			})
+		}
+	}
+}
+
+// RPKIStats returns the number of paths in each validation state against the
+// VRP set installed with SetVRPs.
+func (r *Rib) RPKIStats() RPKICounts {
+	r.v4mu.RLock()
+	r.v6mu.RLock()
+	defer r.v4mu.RUnlock()
+	defer r.v6mu.RUnlock()
+	return RPKICounts{
+		Valid:    r.v4RPKI.Valid + r.v6RPKI.Valid,
+		Invalid:  r.v4RPKI.Invalid + r.v6RPKI.Invalid,
+		NotFound: r.v4RPKI.NotFound + r.v6RPKI.NotFound,
+	}
+}
+
+// RPKIInvalids returns the paths currently invalid against the VRP set
+// installed with SetVRPs, IPv4 before IPv6 in address order.
+func (r *Rib) RPKIInvalids() []Route {
+	var invalid []Route
+
+	r.v4mu.RLock()
+	for key := range r.v4Invalid {
+		if n := r.nodeFor(key.Prefix); n != nil {
+			invalid = append(invalid, n.route(key.Prefix, key.PathID))
+		}
+	}
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	for key := range r.v6Invalid {
+		if n := r.nodeFor(key.Prefix); n != nil {
+			invalid = append(invalid, n.route(key.Prefix, key.PathID))
+		}
+	}
+	r.v6mu.RUnlock()
+
+	sort.Slice(invalid, func(i, j int) bool {
+		if c := comparePrefixes(invalid[i].Prefix, invalid[j].Prefix); c != 0 {
+			return c < 0
+		}
+		return invalid[i].PathID < invalid[j].PathID
+	})
+	return invalid
+}
+
+// rpkiTrack updates the tracked validation state of a path whose attributes
+// change from oldAttr to newAttr; either is nil when the path is added or
+// removed. route supplies the prefix and PathID. The caller must hold the lock
+// of the route's address family.
+func (r *Rib) rpkiTrack(route Route, oldAttr, newAttr *RouteAttributes) {
+	key := PrefixWithID{Prefix: route.Prefix.Masked(), PathID: route.PathID}
+	if oldAttr != nil {
+		origin, ok := pathOrigin(oldAttr)
+		r.rpkiSet(key, r.vrps.validate(key.Prefix, origin, ok), rpkiAbsent)
+	}
+	if newAttr != nil {
+		origin, ok := pathOrigin(newAttr)
+		r.rpkiSet(key, rpkiAbsent, r.vrps.validate(key.Prefix, origin, ok))
+	}
+}
+
+// rpkiSet moves a path from state before to state after. rpkiAbsent stands
+// for a path that is not in the RIB.
+func (r *Rib) rpkiSet(key PrefixWithID, before, after RPKIState) {
+	counts, invalid := &r.v4RPKI, &r.v4Invalid
+	if key.Prefix.Addr().Is6() {
+		counts, invalid = &r.v6RPKI, &r.v6Invalid
+	}
+	if before != rpkiAbsent {
+		counts.add(before, -1)
+		if before == RPKIInvalid {
+			delete(*invalid, key)
+		}
+	}
+	if after != rpkiAbsent {
+		counts.add(after, 1)
+		if after == RPKIInvalid {
+			if *invalid == nil {
+				*invalid = make(map[PrefixWithID]struct{})
+			}
+			(*invalid)[key] = struct{}{}
+		}
+	}
+}
+
+// changedVRPPrefixes returns the smallest set of prefixes covering every VRP
+// present in only one of a and b. Only routes within them can change state.
+func changedVRPPrefixes(a, b *VRPSet) []netip.Prefix {
+	type vrpKey struct {
+		prefix    netip.Prefix
+		maxLength int
+		asn       uint32
+	}
+	seen := make(map[vrpKey]int)
+	for _, v := range a.VRPs() {
+		seen[vrpKey{v.Prefix, v.MaxLength, v.ASN}] |= 1
+	}
+	for _, v := range b.VRPs() {
+		seen[vrpKey{v.Prefix, v.MaxLength, v.ASN}] |= 2
+	}
+
+	var changed []netip.Prefix
+	for k, sides := range seen {
+		if sides != 3 {
+			changed = append(changed, k.prefix)
+		}
+	}
+	return AggregatePrefixes(changed)
+}
+
+// nodeFor returns the trie node for prefix, or nil if the trie has none. The
+// caller must hold the lock of the prefix's address family.
+func (r *Rib) nodeFor(prefix netip.Prefix) *node {
+	addr := prefix.Addr().AsSlice()
+	var n *node
+	if prefix.Addr().Is4() {
+		if prefix.Bits() < 8 || prefix.Bits() > 24 {
+			return nil
+		}
+		n = r.ipv4Root[addr[0]]
+	} else {
+		if prefix.Bits() < 8 || prefix.Bits() > 48 || addr[0] < 0x20 || addr[0] > 0x3F {
+			return nil
+		}
+		n = r.ipv6Root[addr[0]-0x20]
+	}
+	for depth := 8; n != nil && depth < prefix.Bits(); depth++ {
+		n = n.children[addrBit(addr, depth)]
+	}
+	return n
+}
+
+// walkUnder calls fn for every prefix holding paths that lies within p, in
+// address order. The caller must hold the lock of p's address family.
+func (r *Rib) walkUnder(p netip.Prefix, fn func(netip.Prefix, *node)) {
+	p = p.Masked()
+	if p.Bits() < 8 {
+		// Shorter than the root array: visit every /8 slot inside p.
+		first := p.Addr().AsSlice()[0]
+		for i := 0; i < 1<<(8-p.Bits()); i++ {
+			addr := p.Addr().AsSlice()
+			addr[0] = first + byte(i)
+			slot, _ := netip.AddrFromSlice(addr)
+			r.walkUnder(netip.PrefixFrom(slot, 8), fn)
+		}
+		return
+	}
+
+	n := r.nodeFor(p)
+	if n == nil {
+		return
+	}
+	if p.Addr().Is4() {
+		walkNodesV4(n, p.Addr().As4(), p.Bits(), fn)
+	} else {
+		walkNodesV6(n, p.Addr().As16(), p.Bits(), fn)
+	}
+}
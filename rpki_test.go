@@ -0,0 +1,122 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+const rpkiClientJSON = `{
+	"metadata": {"buildtime": "2026-10-19T00:00:00Z", "roas": 3},
+	"roas": [
+		{"asn": 13335, "prefix": "1.0.0.0/24", "maxLength": 24, "ta": "apnic"},
+		{"asn": 15169, "prefix": "8.8.8.0/24", "maxLength": 24, "ta": "arin"},
+		{"asn": 64500, "prefix": "20.0.0.0/16", "maxLength": 20, "ta": "ripe"},
+		{"asn": 64501, "prefix": "2001:db8::/32", "maxLength": 48, "ta": "ripe"}
+	]
+}`
+
+const routinatorJSON = `{
+	"roas": [
+		{"asn": "AS13335", "prefix": "1.0.0.0/24", "maxLength": 24, "ta": "apnic"},
+		{"asn": "AS15169", "prefix": "8.8.8.0/24", "maxLength": 24, "ta": "arin"},
+		{"asn": "AS64500", "prefix": "20.0.0.0/16", "maxLength": 24, "ta": "ripe"},
+		{"asn": "AS64501", "prefix": "2001:db8::/32", "maxLength": 48, "ta": "ripe"}
+	]
+}`
+
+func TestVRPValidate(t *testing.T) {
+	vrps, err := rib.LoadVRPs(strings.NewReader(rpkiClientJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vrps.Len() != 4 {
+		t.Fatalf("expected 4 VRPs, got %d", vrps.Len())
+	}
+
+	tests := []struct {
+		prefix string
+		path   []uint32
+		want   rib.RPKIState
+	}{
+		{"1.0.0.0/24", []uint32{174, 13335}, rib.RPKIValid},
+		{"1.0.0.0/24", []uint32{13335, 174}, rib.RPKIInvalid},
+		{"20.0.0.0/16", []uint32{64500}, rib.RPKIValid},
+		{"20.0.16.0/20", []uint32{64500}, rib.RPKIValid},
+		{"20.0.16.0/24", []uint32{64500}, rib.RPKIInvalid}, // longer than maxLength
+		{"20.0.0.0/15", []uint32{64500}, rib.RPKINotFound},
+		{"9.9.9.0/24", []uint32{19281}, rib.RPKINotFound},
+		{"8.8.8.0/24", nil, rib.RPKIInvalid},
+		{"2001:db8:1::/48", []uint32{64501}, rib.RPKIValid},
+	}
+	for _, tc := range tests {
+		route := rib.Route{Prefix: netip.MustParsePrefix(tc.prefix), Attributes: &rib.RouteAttributes{AsPath: tc.path}}
+		if got := vrps.Validate(route); got != tc.want {
+			t.Errorf("%s %v: got %s, want %s", tc.prefix, tc.path, got, tc.want)
+		}
+	}
+
+	if _, err := rib.LoadVRPs(strings.NewReader(`{"roas": [{"asn": "ASx", "prefix": "1.0.0.0/24"}]}`)); err == nil {
+		t.Error("expected error for invalid asn")
+	}
+	if _, err := rib.LoadVRPs(strings.NewReader(`{"roas": [{"asn": 1, "prefix": "1.0.0.0/24", "maxLength": 16}]}`)); err == nil {
+		t.Error("expected error for maxLength shorter than prefix")
+	}
+}
+
+func TestRibRPKI(t *testing.T) {
+	router := rib.GetNewRib()
+	insert := func(prefix string, path ...uint32) {
+		router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix(prefix), Attributes: &rib.RouteAttributes{AsPath: path}})
+	}
+	insert("1.0.0.0/24", 174, 13335)
+	insert("8.8.8.0/24", 174, 64666)
+	insert("20.0.16.0/24", 64500)
+	insert("9.9.9.0/24", 19281)
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:db8:1::/48"), Attributes: &rib.RouteAttributes{AsPath: []uint32{64501}}})
+
+	old, _ := rib.LoadVRPs(strings.NewReader(rpkiClientJSON))
+	invalid, counts := router.ValidateRPKI(old)
+	if counts != (rib.RPKICounts{Valid: 2, Invalid: 2, NotFound: 1}) {
+		t.Errorf("unexpected counts %+v", counts)
+	}
+	if len(invalid) != 2 || invalid[0].Prefix != netip.MustParsePrefix("8.8.8.0/24") {
+		t.Errorf("unexpected invalids %v", invalid)
+	}
+
+	router.SetVRPs(old)
+	if got := router.RPKIStats(); got != counts {
+		t.Errorf("tracked counts %+v differ from scan %+v", got, counts)
+	}
+
+	// Updates keep the tracked state current.
+	insert("8.8.8.0/24", 174, 15169)
+	router.DeleteIPv4(netip.MustParsePrefix("9.9.9.0/24"), 0)
+	if got := router.RPKIStats(); got != (rib.RPKICounts{Valid: 3, Invalid: 1}) {
+		t.Errorf("unexpected counts after updates %+v", got)
+	}
+
+	// The new set raises maxLength for 20.0.0.0/16, validating 20.0.16.0/24.
+	updated, err := rib.LoadVRPs(strings.NewReader(routinatorJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.SetVRPs(updated)
+	if got := router.RPKIStats(); got != (rib.RPKICounts{Valid: 4}) {
+		t.Errorf("unexpected counts after reload %+v", got)
+	}
+	if got := router.RPKIInvalids(); len(got) != 0 {
+		t.Errorf("expected no invalids after reload, got %v", got)
+	}
+
+	router.SetVRPs(old)
+	got := router.RPKIInvalids()
+	if len(got) != 1 || got[0].Prefix != netip.MustParsePrefix("20.0.16.0/24") || got[0].Attributes.AsPath[0] != 64500 {
+		t.Errorf("unexpected invalids after second reload %v", got)
+	}
+	if _, counts := router.ValidateRPKI(old); counts != router.RPKIStats() {
+		t.Errorf("tracked counts %+v differ from scan %+v", router.RPKIStats(), counts)
+	}
+}
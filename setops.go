@@ -0,0 +1,144 @@
+package routing_table
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// MergePolicy decides what a set operation keeps when both inputs hold a
+// path for the same prefix and PathID with different attributes.
+type MergePolicy uint8
+
+const (
+	// MergeKeepA keeps the path from the first RIB.
+	MergeKeepA MergePolicy = iota
+	// MergeKeepB keeps the path from the second RIB.
+	MergeKeepB
+	// MergeKeepBoth keeps the first RIB's path under its PathID and gives the
+	// second RIB's path the next PathID not used on that prefix, wrapping
+	// round to the lowest free one after 0xFFFFFFFF.
+	MergeKeepBoth
+)
+
+func (p MergePolicy) String() string {
+	switch p {
+	case MergeKeepA:
+		return "keep-a"
+	case MergeKeepB:
+		return "keep-b"
+	case MergeKeepBoth:
+		return "keep-both"
+	}
+	return fmt.Sprintf("MergePolicy(%d)", uint8(p))
+}
+
+// Union returns a new RIB holding every prefix of a and b. Paths present in
+// both with identical attributes are stored once; conflicting paths are
+// resolved by policy.
+func Union(a, b *Rib, policy MergePolicy) Rib {
+	if a == b {
+		return a.Clone()
+	}
+	out := GetNewRib()
+	a.diffNodes(b, func(prefix netip.Prefix, na, nb *node) bool {
+		out.insertMerged(prefix, na, nb, policy)
+		return true
+	})
+	return out
+}
+
+// Intersection returns a new RIB holding the prefixes present in both a and
+// b, with their paths merged as in Union.
+func Intersection(a, b *Rib, policy MergePolicy) Rib {
+	if a == b {
+		return a.Clone()
+	}
+	out := GetNewRib()
+	a.diffNodes(b, func(prefix netip.Prefix, na, nb *node) bool {
+		if na != nil && len(na.paths) > 0 && nb != nil && len(nb.paths) > 0 {
+			out.insertMerged(prefix, na, nb, policy)
+		}
+		return true
+	})
+	return out
+}
+
+// Difference returns a new RIB holding the prefixes of a that are not in b,
+// with all of a's paths for them.
+func Difference(a, b *Rib) Rib {
+	out := GetNewRib()
+	if a == b {
+		return out
+	}
+	a.diffNodes(b, func(prefix netip.Prefix, na, nb *node) bool {
+		if nb == nil || len(nb.paths) == 0 {
+			out.insertMerged(prefix, na, nil, MergeKeepA)
+		}
+		return true
+	})
+	return out
+}
+
+// insertMerged stores the merged paths of na and nb (either may be nil) for
+// prefix in r, interning attributes through r's own table. r must not be
+// visible to other goroutines yet.
+func (r *Rib) insertMerged(prefix netip.Prefix, na, nb *node, policy MergePolicy) {
+	insert := r.insertIPv4Unlocked
+	if prefix.Addr().Is6() {
+		insert = r.insertIPv6Unlocked
+	}
+
+	var used []uint32
+	if na != nil {
+		for id, attr := range na.paths {
+			if nb != nil && policy == MergeKeepB && nb.paths[id] != nil {
+				continue
+			}
+			insert(Route{Prefix: prefix, Attributes: attr, PathID: id})
+			used = append(used, id)
+		}
+	}
+	if nb == nil {
+		return
+	}
+
+	// Walk b's paths in PathID order so KeepBoth renumbering is deterministic.
+	ids := make([]uint32, 0, len(nb.paths))
+	for id := range nb.paths {
+		ids = append(ids, id)
+		used = append(used, id)
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		attr := nb.paths[id]
+		if na != nil {
+			if old := na.paths[id]; old != nil {
+				if old.hash == attr.hash && equalAttributes(old, attr) {
+					continue
+				}
+				switch policy {
+				case MergeKeepA:
+					continue
+				case MergeKeepBoth:
+					id = freePathID(used)
+					used = append(used, id)
+				}
+			}
+		}
+		insert(Route{Prefix: prefix, Attributes: attr, PathID: id})
+	}
+}
+
+// freePathID returns the first PathID after the highest in used that is not
+// in used, wrapping round past 0xFFFFFFFF.
+func freePathID(used []uint32) uint32 {
+	id := slices.Max(used)
+	for {
+		id++ // wraps to 0
+		if !slices.Contains(used, id) {
+			return id
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package routing_table_test
+
+import (
+	"math"
+	"net/netip"
+	"slices"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func pathIDs(routes []rib.Route) []uint32 {
+	var ids []uint32
+	for _, r := range routes {
+		ids = append(ids, r.PathID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSetOperations(t *testing.T) {
+	x := &rib.RouteAttributes{AsPath: []uint32{1, 2}}
+	y := &rib.RouteAttributes{AsPath: []uint32{1, 3}}
+	shared := netip.MustParsePrefix("10.0.0.0/8")
+	onlyA := netip.MustParsePrefix("10.1.0.0/16")
+	onlyB := netip.MustParsePrefix("10.2.0.0/16")
+	v6 := netip.MustParsePrefix("2001:db8::/32")
+
+	a := rib.GetNewRib()
+	a.InsertIPv4(rib.Route{Prefix: shared, Attributes: x})
+	a.InsertIPv4(rib.Route{Prefix: shared, Attributes: x, PathID: 1})
+	a.InsertIPv4(rib.Route{Prefix: onlyA, Attributes: x})
+	a.InsertIPv6(rib.Route{Prefix: v6, Attributes: x})
+
+	b := rib.GetNewRib()
+	b.InsertIPv4(rib.Route{Prefix: shared, Attributes: x})            // identical
+	b.InsertIPv4(rib.Route{Prefix: shared, Attributes: y, PathID: 1}) // conflict
+	b.InsertIPv4(rib.Route{Prefix: onlyB, Attributes: y})
+	b.InsertIPv6(rib.Route{Prefix: v6, Attributes: x})
+
+	t.Run("union keep a", func(t *testing.T) {
+		u := rib.Union(&a, &b, rib.MergeKeepA)
+		if u.V4Count() != 3 || u.V6Count() != 1 || u.V4PathCount() != 4 {
+			t.Fatalf("got %d/%d prefixes and %d v4 paths", u.V4Count(), u.V6Count(), u.V4PathCount())
+		}
+		for _, r := range u.AllPathsIPv4(shared) {
+			if r.PathID == 1 && r.Attributes.AsPath[1] != 2 {
+				t.Errorf("expected path 1 from a, got %v", r.Attributes.AsPath)
+			}
+		}
+		if u.LookupIPv4(onlyB) == nil {
+			t.Errorf("expected %s from b in union", onlyB)
+		}
+	})
+
+	t.Run("union keep b", func(t *testing.T) {
+		u := rib.Union(&a, &b, rib.MergeKeepB)
+		for _, r := range u.AllPathsIPv4(shared) {
+			if r.PathID == 1 && r.Attributes.AsPath[1] != 3 {
+				t.Errorf("expected path 1 from b, got %v", r.Attributes.AsPath)
+			}
+		}
+	})
+
+	t.Run("union keep both", func(t *testing.T) {
+		u := rib.Union(&a, &b, rib.MergeKeepBoth)
+		paths := u.AllPathsIPv4(shared)
+		if got := pathIDs(paths); !slices.Equal(got, []uint32{0, 1, 2}) {
+			t.Fatalf("expected path IDs [0 1 2], got %v", got)
+		}
+		for _, r := range paths {
+			if r.PathID == 2 && r.Attributes.AsPath[1] != 3 {
+				t.Errorf("expected renumbered path from b, got %v", r.Attributes.AsPath)
+			}
+		}
+		if s := u.Stats(0); s.UniqueAttributes != 2 {
+			t.Errorf("expected 2 interned attribute sets, got %d", s.UniqueAttributes)
+		}
+	})
+
+	t.Run("union keep both wraps path ids", func(t *testing.T) {
+		x, y := rib.GetNewRib(), rib.GetNewRib()
+		for _, id := range []uint32{0, 1, math.MaxUint32} {
+			x.InsertIPv4(rib.Route{Prefix: shared, Attributes: &rib.RouteAttributes{AsPath: []uint32{1, 2}}, PathID: id})
+		}
+		y.InsertIPv4(rib.Route{Prefix: shared, Attributes: &rib.RouteAttributes{AsPath: []uint32{1, 3}}, PathID: math.MaxUint32})
+		u := rib.Union(&x, &y, rib.MergeKeepBoth)
+		if got := pathIDs(u.AllPathsIPv4(shared)); !slices.Equal(got, []uint32{0, 1, 2, math.MaxUint32}) {
+			t.Fatalf("expected path IDs [0 1 2 %d], got %v", uint32(math.MaxUint32), got)
+		}
+	})
+
+	t.Run("intersection", func(t *testing.T) {
+		i := rib.Intersection(&a, &b, rib.MergeKeepA)
+		if i.V4Count() != 1 || i.V6Count() != 1 || i.V4PathCount() != 2 {
+			t.Fatalf("got %d/%d prefixes and %d v4 paths", i.V4Count(), i.V6Count(), i.V4PathCount())
+		}
+		if i.LookupIPv4(shared) == nil {
+			t.Errorf("expected %s in intersection", shared)
+		}
+	})
+
+	t.Run("difference", func(t *testing.T) {
+		d := rib.Difference(&a, &b)
+		if d.V4Count() != 1 || d.V6Count() != 0 || d.LookupIPv4(onlyA) == nil {
+			t.Fatalf("expected only %s, got %v", onlyA, d.AllPrefixesIPv4())
+		}
+		if e := rib.Difference(&a, &a); e.V4Count() != 0 || e.V6Count() != 0 {
+			t.Errorf("expected empty difference with itself")
+		}
+	})
+
+	// Inputs are untouched.
+	if a.V4PathCount() != 3 || b.V4PathCount() != 3 {
+		t.Errorf("inputs modified: %d and %d v4 paths", a.V4PathCount(), b.V4PathCount())
+	}
+}
@@ -0,0 +1,192 @@
+package routing_table
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// OriginStats summarises the prefixes announced by one origin ASN.
+type OriginStats struct {
+	ASN        uint32
+	V4Prefixes int
+	V6Prefixes int
+	// V4Addresses is the number of IPv4 addresses announced, and V6Slash48s
+	// the number of /48s. Overlapping prefixes are summed independently, so
+	// space covered by both a prefix and its more-specifics counts twice.
+	V4Addresses uint64
+	V6Slash48s  uint64
+}
+
+// TableStats holds routing table statistics beyond the mask distributions
+// returned by GetSubnets.
+type TableStats struct {
+	// TopOrigins lists the origin ASNs announcing the most prefixes, ties broken
+	// by address space and then ASN.
+	TopOrigins []OriginStats
+	// Origins is the number of distinct origin ASNs.
+	Origins int
+
+	// Paths is the total number of paths over both families.
+	Paths int
+	// PathLengths maps AS path length to number of paths.
+	PathLengths map[int]int
+	// PrependedPaths is the number of paths where at least one ASN is repeated
+	// consecutively. Prepends maps the number of extra copies in a path to the
+	// number of paths with that many.
+	PrependedPaths int
+	Prepends       map[int]int
+	// PathsPerPrefix maps the number of Add-Path paths on a prefix to the number
+	// of prefixes with that many.
+	PathsPerPrefix map[int]int
+
+	// UniqueAttributes is the number of distinct interned attribute sets, and
+	// SharingRatio the average number of paths referencing each one.
+	UniqueAttributes uint64
+	SharingRatio     float64
+}
+
+// Stats walks the RIB and computes table statistics, keeping the topN origin
+// ASNs by prefix count. A topN of zero or less keeps every origin.
+func (r *Rib) Stats(topN int) TableStats {
+	s := TableStats{
+		PathLengths:    make(map[int]int),
+		Prepends:       make(map[int]int),
+		PathsPerPrefix: make(map[int]int),
+	}
+	origins := make(map[uint32]*OriginStats)
+
+	visit := func(prefix netip.Prefix, n *node) {
+		s.PathsPerPrefix[len(n.paths)]++
+
+		// Count each origin once per prefix even if several paths share it.
+		var seen [4]uint32
+		seenOrigins := seen[:0]
+		for _, attr := range n.paths {
+			s.Paths++
+			path := attr.AsPath
+			s.PathLengths[len(path)]++
+			if extra := prependCount(path); extra > 0 {
+				s.PrependedPaths++
+				s.Prepends[extra]++
+			}
+			if len(path) == 0 {
+				continue
+			}
+			origin := path[len(path)-1]
+			dup := false
+			for _, o := range seenOrigins {
+				if o == origin {
+					dup = true
+					break
+				}
+			}
+			if dup {
+				continue
+			}
+			seenOrigins = append(seenOrigins, origin)
+
+			o := origins[origin]
+			if o == nil {
+				o = &OriginStats{ASN: origin}
+				origins[origin] = o
+			}
+			if prefix.Addr().Is4() {
+				o.V4Prefixes++
+				o.V4Addresses += 1 << (32 - prefix.Bits())
+			} else {
+				o.V6Prefixes++
+				o.V6Slash48s += 1 << (48 - prefix.Bits())
+			}
+		}
+	}
+
+	// Hold both families so the path counts and the attribute table counters
+	// come from the same snapshot.
+	r.v4mu.RLock()
+	r.v6mu.RLock()
+	r.walkIPv4(visit)
+	r.walkIPv6(visit)
+	s.UniqueAttributes, _ = r.attrTable.GetStats()
+	r.v6mu.RUnlock()
+	r.v4mu.RUnlock()
+
+	s.Origins = len(origins)
+	s.TopOrigins = make([]OriginStats, 0, len(origins))
+	for _, o := range origins {
+		s.TopOrigins = append(s.TopOrigins, *o)
+	}
+	sort.Slice(s.TopOrigins, func(i, j int) bool {
+		a, b := &s.TopOrigins[i], &s.TopOrigins[j]
+		if pa, pb := a.V4Prefixes+a.V6Prefixes, b.V4Prefixes+b.V6Prefixes; pa != pb {
+			return pa > pb
+		}
+		if a.V4Addresses != b.V4Addresses {
+			return a.V4Addresses > b.V4Addresses
+		}
+		if a.V6Slash48s != b.V6Slash48s {
+			return a.V6Slash48s > b.V6Slash48s
+		}
+		return a.ASN < b.ASN
+	})
+	if topN > 0 && len(s.TopOrigins) > topN {
+		s.TopOrigins = s.TopOrigins[:topN]
+	}
+
+	if s.UniqueAttributes > 0 {
+		s.SharingRatio = float64(s.Paths) / float64(s.UniqueAttributes)
+	}
+	return s
+}
+
+// prependCount returns how many ASNs in path repeat the ASN before them.
+func prependCount(path []uint32) int {
+	extra := 0
+	for i := 1; i < len(path); i++ {
+		if path[i] == path[i-1] {
+			extra++
+		}
+	}
+	return extra
+}
+
+func (s TableStats) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Origin ASNs: %d\n", s.Origins)
+	if len(s.TopOrigins) > 0 {
+		fmt.Fprintf(&b, "%12s %10s %10s %14s %12s\n", "Origin", "IPv4", "IPv6", "IPv4 addrs", "IPv6 /48s")
+		for _, o := range s.TopOrigins {
+			fmt.Fprintf(&b, "%12s %10d %10d %14d %12d\n",
+				fmt.Sprintf("AS%d", o.ASN), o.V4Prefixes, o.V6Prefixes, o.V4Addresses, o.V6Slash48s)
+		}
+	}
+
+	prepended := 0.0
+	if s.Paths > 0 {
+		prepended = float64(s.PrependedPaths) / float64(s.Paths) * 100
+	}
+	fmt.Fprintf(&b, "Paths: %d, prepended: %d (%.1f%%)\n", s.Paths, s.PrependedPaths, prepended)
+	writeHistogram(&b, "AS path length", s.PathLengths)
+	writeHistogram(&b, "Prepended copies", s.Prepends)
+	writeHistogram(&b, "Paths per prefix", s.PathsPerPrefix)
+	fmt.Fprintf(&b, "Attribute sets: %d, sharing ratio: %.2f paths/set\n", s.UniqueAttributes, s.SharingRatio)
+	return b.String()
+}
+
+// writeHistogram writes one "  key: count" line per bucket in key order.
+func writeHistogram(b *strings.Builder, title string, h map[int]int) {
+	if len(h) == 0 {
+		return
+	}
+	keys := make([]int, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	fmt.Fprintf(b, "%s:\n", title)
+	for _, k := range keys {
+		fmt.Fprintf(b, "  %4d: %d\n", k, h[k])
+	}
+}
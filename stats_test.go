@@ -0,0 +1,63 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestStats(t *testing.T) {
+	router := rib.GetNewRib()
+	google := &rib.RouteAttributes{AsPath: []uint32{3356, 15169}}
+	googlePrepended := &rib.RouteAttributes{AsPath: []uint32{174, 15169, 15169, 15169}}
+	cloudflare := &rib.RouteAttributes{AsPath: []uint32{13335}}
+
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("8.8.8.0/24"), Attributes: google})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("8.8.8.0/24"), Attributes: googlePrepended, PathID: 1})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("8.8.4.0/24"), Attributes: google})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.1.1.0/24"), Attributes: cloudflare})
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("1.0.0.0/16"), Attributes: cloudflare})
+	router.InsertIPv6(rib.Route{Prefix: netip.MustParsePrefix("2001:4860::/32"), Attributes: google})
+
+	s := router.Stats(1)
+	if s.Origins != 2 {
+		t.Errorf("expected 2 origins, got %d", s.Origins)
+	}
+	if len(s.TopOrigins) != 1 {
+		t.Fatalf("expected top 1 origin, got %d", len(s.TopOrigins))
+	}
+	top := s.TopOrigins[0]
+	if top.ASN != 15169 || top.V4Prefixes != 2 || top.V6Prefixes != 1 || top.V4Addresses != 512 || top.V6Slash48s != 65536 {
+		t.Errorf("unexpected top origin %+v", top)
+	}
+
+	all := router.Stats(0)
+	if len(all.TopOrigins) != 2 || all.TopOrigins[1].V4Addresses != 65536+256 {
+		t.Errorf("unexpected origins %+v", all.TopOrigins)
+	}
+
+	if s.Paths != 6 {
+		t.Errorf("expected 6 paths, got %d", s.Paths)
+	}
+	if s.PathLengths[2] != 3 || s.PathLengths[4] != 1 || s.PathLengths[1] != 2 {
+		t.Errorf("unexpected path length histogram %v", s.PathLengths)
+	}
+	if s.PrependedPaths != 1 || s.Prepends[2] != 1 {
+		t.Errorf("unexpected prepends %d %v", s.PrependedPaths, s.Prepends)
+	}
+	if s.PathsPerPrefix[1] != 4 || s.PathsPerPrefix[2] != 1 {
+		t.Errorf("unexpected paths per prefix %v", s.PathsPerPrefix)
+	}
+	if s.UniqueAttributes != 3 || s.SharingRatio != 2 {
+		t.Errorf("expected 3 attribute sets shared 2x, got %d and %.2f", s.UniqueAttributes, s.SharingRatio)
+	}
+
+	report := s.String()
+	for _, want := range []string{"AS15169", "AS path length:", "sharing ratio: 2.00"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package routing_table
+
+import (
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// TimestampConfig enables first-seen and last-modified times per path.
+type TimestampConfig struct {
+	// Clock returns the current time. It defaults to time.Now and can be
+	// replaced to make timestamps deterministic.
+	Clock func() time.Time
+}
+
+func (c *TimestampConfig) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock()
+	}
+	return time.Now()
+}
+
+// SetTimestamps enables per-path timestamps with cfg, or disables them and
+// discards every recorded timestamp when cfg is nil. Paths already in the
+// RIB when timestamps are enabled have no first-seen time, and get a
+// last-modified time with their next attribute change.
+func (r *Rib) SetTimestamps(cfg *TimestampConfig) {
+	r.v4mu.Lock()
+	r.v6mu.Lock()
+	defer r.v4mu.Unlock()
+	defer r.v6mu.Unlock()
+
+	if cfg == nil && r.timestamps != nil {
+		reset := func(_ netip.Prefix, n *node) {
+			for id, m := range n.meta {
+				m.firstSeen, m.lastModified = time.Time{}, time.Time{}
+				n.tidyMeta(id)
+			}
+		}
+		r.walkIPv4(reset)
+		r.walkIPv6(reset)
+	}
+	r.timestamps = cfg
+}
+
+// stamp records the times of an insert that stored newAttr for pathID on n,
+// replacing oldAttr (nil for a new path). Last-modified only moves when the
+// attributes actually change. The caller must hold the lock of the family.
+func (r *Rib) stamp(n *node, pathID uint32, oldAttr, newAttr *RouteAttributes) {
+	if oldAttr == newAttr {
+		return
+	}
+	now := r.timestamps.now()
+	m := n.pathMeta(pathID, true)
+	if oldAttr == nil {
+		m.firstSeen = now
+	}
+	m.lastModified = now
+}
+
+// ChangedSince returns every path added or whose attributes changed at or
+// after t, IPv4 before IPv6 in address order. Withdrawn paths are not
+// reported; use a Diff against an earlier Clone for those.
+func (r *Rib) ChangedSince(t time.Time) []Route {
+	var changed []Route
+	r.timedPaths(func(rt Route) {
+		if !rt.LastModified.Before(t) {
+			changed = append(changed, rt)
+		}
+	})
+	return changed
+}
+
+// OldestRoutes returns up to n paths with the earliest first-seen times,
+// oldest first. Paths without a first-seen time are left out. A negative n
+// returns no paths.
+func (r *Rib) OldestRoutes(n int) []Route {
+	return r.routesByAge(n, false)
+}
+
+// NewestRoutes returns up to n paths with the latest first-seen times,
+// newest first. Paths without a first-seen time are left out. A negative n
+// returns no paths.
+func (r *Rib) NewestRoutes(n int) []Route {
+	return r.routesByAge(n, true)
+}
+
+func (r *Rib) routesByAge(n int, newest bool) []Route {
+	var routes []Route
+	r.timedPaths(func(rt Route) {
+		if !rt.FirstSeen.IsZero() {
+			routes = append(routes, rt)
+		}
+	})
+	// Stable, so equal times keep address order.
+	slices.SortStableFunc(routes, func(a, b Route) int {
+		if newest {
+			return b.FirstSeen.Compare(a.FirstSeen)
+		}
+		return a.FirstSeen.Compare(b.FirstSeen)
+	})
+	return routes[:min(max(n, 0), len(routes))]
+}
+
+// timedPaths calls fn for every path with timestamps, IPv4 before IPv6 in
+// address order and by PathID within a prefix.
+func (r *Rib) timedPaths(fn func(Route)) {
+	visit := func(prefix netip.Prefix, n *node) {
+		if n.meta == nil {
+			return
+		}
+		for _, rt := range sortedRoutes(n, prefix) {
+			if !rt.LastModified.IsZero() {
+				fn(rt)
+			}
+		}
+	}
+
+	r.v4mu.RLock()
+	r.walkIPv4(visit)
+	r.v4mu.RUnlock()
+
+	r.v6mu.RLock()
+	r.walkIPv6(visit)
+	r.v6mu.RUnlock()
+}
@@ -0,0 +1,89 @@
+package routing_table_test
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+func TestTimestamps(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+
+	router := rib.GetNewRib()
+	router.InsertIPv4(rib.Route{Prefix: netip.MustParsePrefix("9.9.9.0/24")})
+	router.SetTimestamps(&rib.TimestampConfig{Clock: clock})
+
+	a := &rib.RouteAttributes{AsPath: []uint32{174, 13335}}
+	b := &rib.RouteAttributes{AsPath: []uint32{3356, 13335}}
+	p4 := netip.MustParsePrefix("1.1.1.0/24")
+	p6 := netip.MustParsePrefix("2606:4700::/32")
+
+	router.InsertIPv4(rib.Route{Prefix: p4, Attributes: a})
+	now = now.Add(time.Minute)
+	router.InsertIPv6(rib.Route{Prefix: p6, Attributes: a})
+	router.InsertIPv4(rib.Route{Prefix: p4, Attributes: b, PathID: 1})
+
+	// Re-announcing identical attributes is not a modification.
+	now = now.Add(time.Minute)
+	router.InsertIPv4(rib.Route{Prefix: p4, Attributes: &rib.RouteAttributes{AsPath: []uint32{174, 13335}}})
+	got := router.LookupIPv4(p4)
+	if !got.FirstSeen.Equal(start) || !got.LastModified.Equal(start) {
+		t.Errorf("expected unchanged timestamps, got %v %v", got.FirstSeen, got.LastModified)
+	}
+
+	// A changed path keeps its first-seen time.
+	now = now.Add(time.Minute)
+	router.InsertIPv4(rib.Route{Prefix: p4, Attributes: b})
+	paths := router.AllPathsIPv4(p4)
+	for _, rt := range paths {
+		if rt.PathID == 0 && (!rt.FirstSeen.Equal(start) || !rt.LastModified.Equal(start.Add(3*time.Minute))) {
+			t.Errorf("path 0: got %v %v", rt.FirstSeen, rt.LastModified)
+		}
+	}
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(paths))
+	}
+
+	changed := router.ChangedSince(start.Add(time.Minute))
+	if len(changed) != 3 || changed[0].Prefix != p4 || changed[0].PathID != 0 || changed[1].PathID != 1 || changed[2].Prefix != p6 {
+		t.Errorf("unexpected changed routes %v", changed)
+	}
+	if got := router.ChangedSince(start.Add(2 * time.Minute)); len(got) != 1 || got[0].PathID != 0 {
+		t.Errorf("expected only path 0 changed in the last minute, got %v", got)
+	}
+
+	// 9.9.9.0/24 predates timestamps and is left out.
+	oldest := router.OldestRoutes(2)
+	if len(oldest) != 2 || oldest[0].Prefix != p4 || oldest[0].PathID != 0 || oldest[1].Prefix != p4 || oldest[1].PathID != 1 {
+		t.Errorf("unexpected oldest routes %v", oldest)
+	}
+	newest := router.NewestRoutes(10)
+	if len(newest) != 3 || newest[0].Prefix != p4 || newest[0].PathID != 1 || newest[1].Prefix != p6 || newest[2].PathID != 0 {
+		t.Errorf("unexpected newest routes %v", newest)
+	}
+	if got := router.OldestRoutes(-1); len(got) != 0 {
+		t.Errorf("expected no routes for a negative count, got %v", got)
+	}
+
+	c := router.Clone()
+	if got := c.LookupIPv6(p6); !got.FirstSeen.Equal(start.Add(time.Minute)) {
+		t.Errorf("clone lost timestamps: %v", got.FirstSeen)
+	}
+
+	// Withdrawing and re-announcing starts a new first-seen time.
+	router.DeleteIPv6(p6, 0)
+	now = now.Add(time.Minute)
+	router.InsertIPv6(rib.Route{Prefix: p6, Attributes: a})
+	if got := router.LookupIPv6(p6); !got.FirstSeen.Equal(now) {
+		t.Errorf("expected first-seen reset after withdrawal, got %v", got.FirstSeen)
+	}
+
+	router.SetTimestamps(nil)
+	if got := router.LookupIPv4(p4); !got.FirstSeen.IsZero() || len(router.ChangedSince(time.Time{})) != 0 {
+		t.Errorf("expected timestamps discarded, got %v", got.FirstSeen)
+	}
+}
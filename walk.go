@@ -0,0 +1,236 @@
+package routing_table
+
+import (
+	"context"
+	"net/netip"
+	"regexp"
+)
+
+// walkChunk is the number of routed prefixes visited per read lock hold by the
+// context-aware walks. Between chunks the lock is released, so writers are
+// not blocked for the whole walk, and the context is checked.
+const walkChunk = 1024
+
+// walkContext calls fn for every prefix of one address family holding at
+// least one path, in address order, in chunks of walkChunk prefixes. The
+// family lock is held during each chunk and released between them, and the
+// next chunk resumes after the last prefix visited, skipping every subtree
+// before it. Routes changed between chunks may or may not be seen, so the
+// walk is not a consistent snapshot. It returns ctx.Err() if ctx is done
+// before the walk completes.
+func (r *Rib) walkContext(ctx context.Context, ipv6 bool, fn func(prefix netip.Prefix, n *node)) error {
+	mu := r.v4mu
+	if ipv6 {
+		mu = r.v6mu
+	}
+
+	var cursor netip.Prefix
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		visited := 0
+		visit := func(prefix netip.Prefix, n *node) bool {
+			if visited == walkChunk {
+				return false
+			}
+			fn(prefix, n)
+			cursor = prefix
+			visited++
+			return true
+		}
+
+		mu.RLock()
+		var done bool
+		if ipv6 {
+			done = r.walkIPv6From(cursor, visit)
+		} else {
+			done = r.walkIPv4From(cursor, visit)
+		}
+		mu.RUnlock()
+
+		if done {
+			return nil
+		}
+	}
+}
+
+// walkIPv4From calls fn for every routed IPv4 prefix after cursor in address
+// order, or every one if cursor is the zero Prefix, until fn returns false.
+// It reports whether the walk completed. The caller must hold v4mu.
+func (r *Rib) walkIPv4From(cursor netip.Prefix, fn func(netip.Prefix, *node) bool) bool {
+	for i := 0; i < 256; i++ {
+		if r.ipv4Root[i] != nil {
+			var addr [4]byte
+			addr[0] = byte(i)
+			if !walkNodesFromV4(r.ipv4Root[i], addr, 8, cursor, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// walkIPv6From is walkIPv4From for IPv6. The caller must hold v6mu.
+func (r *Rib) walkIPv6From(cursor netip.Prefix, fn func(netip.Prefix, *node) bool) bool {
+	for i := 0; i < 32; i++ {
+		if r.ipv6Root[i] != nil {
+			var addr [16]byte
+			addr[0] = byte(i + 0x20)
+			if !walkNodesFromV6(r.ipv6Root[i], addr, 8, cursor, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// afterCursor classifies a subtree rooted at prefix against cursor: visit
+// means the whole subtree comes after cursor, descend means prefix covers
+// cursor so only some of its descendants do, and neither means the whole
+// subtree comes before cursor and can be skipped.
+func afterCursor(prefix, cursor netip.Prefix) (visit, descend bool) {
+	if !cursor.IsValid() || comparePrefixes(prefix, cursor) > 0 {
+		return true, false
+	}
+	return false, prefix.Bits() <= cursor.Bits() && prefix.Contains(cursor.Addr())
+}
+
+// walkNodesFromV4 is walkNodesV4 resuming after cursor and stopping when fn
+// returns false. It reports whether the subtree was walked to the end.
+func walkNodesFromV4(n *node, addr [4]byte, depth int, cursor netip.Prefix, fn func(netip.Prefix, *node) bool) bool {
+	prefix := netip.PrefixFrom(netip.AddrFrom4(addr), depth)
+	visit, descend := afterCursor(prefix, cursor)
+	if !visit && !descend {
+		return true
+	}
+	if visit {
+		cursor = netip.Prefix{}
+		if len(n.paths) > 0 && !fn(prefix, n) {
+			return false
+		}
+	}
+
+	if depth >= 24 {
+		return true
+	}
+
+	for bit := 0; bit < 2; bit++ {
+		if n.children[bit] != nil {
+			nextAddr := addr
+			byteIdx := depth / 8
+			bitPos := uint(7 - (depth % 8))
+			if bit == 1 {
+				nextAddr[byteIdx] |= 1 << bitPos
+			}
+			if !walkNodesFromV4(n.children[bit], nextAddr, depth+1, cursor, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// walkNodesFromV6 is walkNodesFromV4 for IPv6.
+func walkNodesFromV6(n *node, addr [16]byte, depth int, cursor netip.Prefix, fn func(netip.Prefix, *node) bool) bool {
+	prefix := netip.PrefixFrom(netip.AddrFrom16(addr), depth)
+	visit, descend := afterCursor(prefix, cursor)
+	if !visit && !descend {
+		return true
+	}
+	if visit {
+		cursor = netip.Prefix{}
+		if len(n.paths) > 0 && !fn(prefix, n) {
+			return false
+		}
+	}
+
+	if depth >= 48 {
+		return true
+	}
+
+	for bit := 0; bit < 2; bit++ {
+		if n.children[bit] != nil {
+			nextAddr := addr
+			byteIdx := depth / 8
+			bitPos := uint(7 - (depth % 8))
+			if bit == 1 {
+				nextAddr[byteIdx] |= 1 << bitPos
+			}
+			if !walkNodesFromV6(n.children[bit], nextAddr, depth+1, cursor, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// walkBothContext runs walkContext over IPv4 and then IPv6, stopping at the
+// first error.
+func (r *Rib) walkBothContext(ctx context.Context, v4, v6 func(netip.Prefix, *node)) error {
+	if err := r.walkContext(ctx, false, v4); err != nil {
+		return err
+	}
+	return r.walkContext(ctx, true, v6)
+}
+
+// PrefixesByOriginASNContext is PrefixesByOriginASN for large tables: it
+// walks in chunks, releasing the read locks between them, and stops once ctx
+// is done. On cancellation it returns the routes found so far, in address
+// order, together with ctx.Err().
+func (r *Rib) PrefixesByOriginASNContext(ctx context.Context, asn uint32) (v4 []Route, v6 []Route, err error) {
+	collect := func(results *[]Route) func(netip.Prefix, *node) {
+		return func(prefix netip.Prefix, n *node) {
+			for id, attr := range n.paths {
+				path := attr.AsPath
+				if len(path) > 0 && path[len(path)-1] == asn {
+					*results = append(*results, n.route(prefix, id))
+				}
+			}
+		}
+	}
+	err = r.walkBothContext(ctx, collect(&v4), collect(&v6))
+	return v4, v6, err
+}
+
+// PrefixesByAsPathRegexContext is PrefixesByAsPathRegex for large tables,
+// with the chunking and cancellation of PrefixesByOriginASNContext.
+func (r *Rib) PrefixesByAsPathRegexContext(ctx context.Context, re *regexp.Regexp) (v4 []Route, v6 []Route, err error) {
+	collect := func(results *[]Route) func(netip.Prefix, *node) {
+		return func(prefix netip.Prefix, n *node) {
+			for id, attr := range n.paths {
+				if re.MatchString(attr.ASPathString()) {
+					*results = append(*results, n.route(prefix, id))
+				}
+			}
+		}
+	}
+	err = r.walkBothContext(ctx, collect(&v4), collect(&v6))
+	return v4, v6, err
+}
+
+// AllPrefixesIPv4Context is AllPrefixesIPv4 for large tables, with the
+// chunking and cancellation of PrefixesByOriginASNContext.
+func (r *Rib) AllPrefixesIPv4Context(ctx context.Context) ([]netip.Prefix, error) {
+	if r.v4mu == nil {
+		return nil, nil
+	}
+	var prefixes []netip.Prefix
+	err := r.walkContext(ctx, false, func(prefix netip.Prefix, _ *node) {
+		prefixes = append(prefixes, prefix)
+	})
+	return prefixes, err
+}
+
+// AllPrefixesIPv6Context is AllPrefixesIPv6 for large tables, with the
+// chunking and cancellation of PrefixesByOriginASNContext.
+func (r *Rib) AllPrefixesIPv6Context(ctx context.Context) ([]netip.Prefix, error) {
+	if r.v6mu == nil {
+		return nil, nil
+	}
+	var prefixes []netip.Prefix
+	err := r.walkContext(ctx, true, func(prefix netip.Prefix, _ *node) {
+		prefixes = append(prefixes, prefix)
+	})
+	return prefixes, err
+}
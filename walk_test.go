@@ -0,0 +1,135 @@
+package routing_table_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+
+	rib "github.com/mellowdrifter/routing_table"
+)
+
+// countdownCtx reports cancellation once Err has been called n times.
+type countdownCtx struct {
+	context.Context
+	mu sync.Mutex
+	n  int
+}
+
+func (c *countdownCtx) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.n == 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+// chunkTestRib returns a RIB with a few thousand prefixes of mixed lengths in
+// both families, nested so that chunks resume inside covering prefixes.
+func chunkTestRib() *rib.Rib {
+	router := rib.GetNewRib()
+	for i := 0; i < 3000; i++ {
+		a, b := byte(1+i/256), byte(i%256)
+		attr := &rib.RouteAttributes{AsPath: []uint32{174, uint32(64500 + i%3)}}
+		router.InsertIPv4(rib.Route{Prefix: netip.PrefixFrom(netip.AddrFrom4([4]byte{a, b, 0, 0}), 24), Attributes: attr})
+		if i%4 == 0 {
+			router.InsertIPv4(rib.Route{Prefix: netip.PrefixFrom(netip.AddrFrom4([4]byte{a, b, 0, 0}), 16), Attributes: attr, PathID: 1})
+		}
+		router.InsertIPv6(rib.Route{Prefix: netip.PrefixFrom(netip.AddrFrom16([16]byte{0x20, 0x01, a, b}), 32), Attributes: attr})
+		router.InsertIPv6(rib.Route{Prefix: netip.PrefixFrom(netip.AddrFrom16([16]byte{0x20, 0x01, a, b, 0, 1}), 48), Attributes: attr})
+	}
+	return &router
+}
+
+func sortRoutes(routes []rib.Route) {
+	slices.SortFunc(routes, func(a, b rib.Route) int {
+		if c := a.Prefix.Addr().Compare(b.Prefix.Addr()); c != 0 {
+			return c
+		}
+		if c := a.Prefix.Bits() - b.Prefix.Bits(); c != 0 {
+			return c
+		}
+		return int(a.PathID) - int(b.PathID)
+	})
+}
+
+func TestWalkContextComplete(t *testing.T) {
+	router := chunkTestRib()
+	ctx := context.Background()
+
+	v4, err := router.AllPrefixesIPv4Context(ctx)
+	if err != nil || !slices.Equal(v4, router.AllPrefixesIPv4()) {
+		t.Errorf("IPv4 prefixes differ: %d vs %d, err %v", len(v4), router.V4Count(), err)
+	}
+	v6, err := router.AllPrefixesIPv6Context(ctx)
+	if err != nil || !slices.Equal(v6, router.AllPrefixesIPv6()) {
+		t.Errorf("IPv6 prefixes differ: %d vs %d, err %v", len(v6), router.V6Count(), err)
+	}
+
+	want4, want6 := router.PrefixesByOriginASN(64501)
+	got4, got6, err := router.PrefixesByOriginASNContext(ctx, 64501)
+	sortRoutes(want4)
+	sortRoutes(want6)
+	sortRoutes(got4)
+	sortRoutes(got6)
+	if err != nil || !slices.Equal(got4, want4) || !slices.Equal(got6, want6) {
+		t.Errorf("origin results differ: %d/%d vs %d/%d, err %v", len(got4), len(got6), len(want4), len(want6), err)
+	}
+
+	re := regexp.MustCompile(`^174 64502$`)
+	want4, want6 = router.PrefixesByAsPathRegex(re)
+	got4, got6, err = router.PrefixesByAsPathRegexContext(ctx, re)
+	if err != nil || len(got4) != len(want4) || len(got6) != len(want6) {
+		t.Errorf("regex results differ: %d/%d vs %d/%d, err %v", len(got4), len(got6), len(want4), len(want6), err)
+	}
+}
+
+func TestWalkContextCancel(t *testing.T) {
+	router := chunkTestRib()
+	all := router.AllPrefixesIPv4()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err := router.AllPrefixesIPv4Context(ctx)
+	if !errors.Is(err, context.Canceled) || len(got) != 0 {
+		t.Errorf("expected nothing from a canceled walk, got %d prefixes, err %v", len(got), err)
+	}
+
+	// Cancel after two chunks: the partial result is a prefix of the full walk.
+	got, err = router.AllPrefixesIPv4Context(&countdownCtx{Context: context.Background(), n: 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(got) == 0 || len(got) >= len(all) || !slices.Equal(got, all[:len(got)]) {
+		t.Errorf("expected an ordered partial walk, got %d of %d prefixes", len(got), len(all))
+	}
+
+	// IPv4 completes in a few chunks; canceling during IPv6 keeps the IPv4 results.
+	v4, v6, err := router.PrefixesByOriginASNContext(&countdownCtx{Context: context.Background(), n: 6}, 64500)
+	if !errors.Is(err, context.Canceled) || len(v4) == 0 || len(v6) == 0 {
+		t.Errorf("expected partial results with context.Canceled, got %d/%d, err %v", len(v4), len(v6), err)
+	}
+}
+
+func TestWalkContextConcurrentWrites(t *testing.T) {
+	router := chunkTestRib()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2000; i++ {
+			p := netip.PrefixFrom(netip.AddrFrom4([4]byte{100, byte(i / 256), byte(i), 0}), 24)
+			router.InsertIPv4(rib.Route{Prefix: p})
+			router.DeleteIPv4(p, 0)
+		}
+	}()
+	got, err := router.AllPrefixesIPv4Context(context.Background())
+	<-done
+	if err != nil || len(got) < 3000 || !slices.IsSortedFunc(got, func(a, b netip.Prefix) int { return a.Addr().Compare(b.Addr()) }) {
+		t.Errorf("unexpected walk under concurrent writes: %d prefixes, err %v", len(got), err)
+	}
+}